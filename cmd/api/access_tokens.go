@@ -0,0 +1,158 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alejandro-cardenas-g/social/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+// accessTokenPrefix tells personal access tokens apart from JWTs in the
+// Authorization header and makes leaked tokens easy to scan for.
+const accessTokenPrefix = "gsp_"
+
+const (
+	scopePostsRead  = "posts:read"
+	scopePostsWrite = "posts:write"
+	scopeUsersRead  = "users:read"
+	scopeUsersWrite = "users:write"
+)
+
+type scopesKey string
+
+const scopesCtx scopesKey = "scopes"
+
+type CreateAccessTokenPayload struct {
+	Name          string   `json:"name" validate:"required,max=100"`
+	Scopes        []string `json:"scopes" validate:"required,min=1,dive,oneof=posts:read posts:write users:read users:write"`
+	ExpiresInDays int      `json:"expires_in_days" validate:"required,min=1,max=365"`
+}
+
+type CreatedAccessToken struct {
+	store.AccessToken
+	Token string `json:"token"`
+}
+
+// CreateAccessToken godoc
+//
+//	@Summary		Creates a personal access token
+//	@Description	Creates a personal access token. The token is only returned by this call
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateAccessTokenPayload	true	"Token name, scopes and expiry"
+//	@Success		201		{object}	CreatedAccessToken
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/tokens [post]
+func (app *application) createAccessTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateAccessTokenPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	plainToken := accessTokenPrefix + hex.EncodeToString(secret)
+
+	token := &store.AccessToken{
+		UserID: user.ID,
+		Name:   payload.Name,
+		Token:  hashToken(plainToken),
+		Scopes: payload.Scopes,
+		Expiry: time.Now().Add(time.Hour * 24 * time.Duration(payload.ExpiresInDays)),
+	}
+
+	if err := app.store.AccessTokens.Create(r.Context(), token); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	res := &CreatedAccessToken{
+		AccessToken: *token,
+		Token:       plainToken,
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, res); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ListAccessTokens godoc
+//
+//	@Summary		Lists personal access tokens
+//	@Description	Lists the personal access tokens of the authenticated user
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{object}	[]store.AccessToken
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/tokens [get]
+func (app *application) listAccessTokensHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	tokens, err := app.store.AccessTokens.GetByUserID(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, tokens); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// RevokeAccessToken godoc
+//
+//	@Summary		Revokes a personal access token
+//	@Description	Revokes a personal access token of the authenticated user
+//	@Tags			users
+//	@Produce		json
+//	@Param			tokenID	path		int		true	"Token ID"
+//	@Success		204		{string}	string	"Token revoked"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/tokens/{tokenID} [delete]
+func (app *application) revokeAccessTokenHandler(w http.ResponseWriter, r *http.Request) {
+	tokenID, err := strconv.ParseInt(chi.URLParam(r, "tokenID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	if err := app.store.AccessTokens.Delete(r.Context(), tokenID, user.ID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
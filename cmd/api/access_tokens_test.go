@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/alejandro-cardenas-g/social/internal/store"
+)
+
+func TestAccessTokenAuthentication(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	users := mockAuthenticatedUser(app, &store.User{ID: mockUserID})
+
+	accessTokens := app.store.AccessTokens.(*store.MockAccessTokensStore)
+	accessTokens.On("Authenticate", hashToken("gsp_reader")).Return(&store.AccessToken{UserID: mockUserID, Scopes: []string{scopeUsersRead}}, nil)
+	accessTokens.On("Authenticate", hashToken("gsp_expired")).Return(nil, store.ErrNotFound)
+
+	request := func(t *testing.T, method, path, token string) int {
+		req, err := http.NewRequest(method, path, strings.NewReader(`{}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should authenticate with a personal access token", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodGet, "/v1/users/me", "gsp_reader"))
+	})
+
+	t.Run("should enforce the scopes of the token", func(t *testing.T) {
+		checkResponseCode(t, http.StatusForbidden, request(t, http.MethodPatch, "/v1/users/me", "gsp_reader"))
+	})
+
+	t.Run("should reject an expired or revoked token", func(t *testing.T) {
+		checkResponseCode(t, http.StatusUnauthorized, request(t, http.MethodGet, "/v1/users/me", "gsp_expired"))
+	})
+
+	t.Run("should not accept tokens on session only endpoints", func(t *testing.T) {
+		checkResponseCode(t, http.StatusUnauthorized, request(t, http.MethodGet, "/v1/users/me/tokens", "gsp_reader"))
+	})
+
+	accessTokens.AssertExpectations(t)
+	accessTokens.AssertNumberOfCalls(t, "Authenticate", 3)
+	users.AssertExpectations(t)
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
@@ -35,22 +36,78 @@ type dbConfig struct {
 type SendGridConfig struct {
 	apikey string
 }
+
+type SMTPConfig struct {
+	host     string
+	port     int
+	username string
+	password string
+}
+
 type mailConfig struct {
-	exp       time.Duration
-	fromEmail string
-	sendGrid  SendGridConfig
+	exp          time.Duration
+	resetExp     time.Duration
+	magicLinkExp time.Duration
+	// emailChangeExp is how long the link confirming a new email is valid
+	emailChangeExp time.Duration
+	fromEmail      string
+	// backend is one of sendgrid, smtp or file
+	backend  string
+	sendGrid SendGridConfig
+	smtp     SMTPConfig
+	// fileDir is where the file backend writes the messages
+	fileDir string
+	outbox  outboxConfig
+}
+
+type outboxConfig struct {
+	pollInterval time.Duration
+	batchSize    int
+	// lease is how long a claimed email is hidden from other dispatchers
+	lease       time.Duration
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	// retention is how long sent and dead-lettered emails are kept
+	retention time.Duration
 }
 
 type config struct {
-	addr        string
-	db          dbConfig
-	env         string
-	apiHost     string
-	frontendURL string
-	mail        mailConfig
-	auth        authConfig
-	redisCfg    redisConfig
-	rateLimiter ratelimiter.Config
+	addr             string
+	db               dbConfig
+	env              string
+	apiHost          string
+	apiURL           string
+	frontendURL      string
+	mail             mailConfig
+	auth             authConfig
+	redisCfg         redisConfig
+	rateLimiter      ratelimiter.Config
+	emailRateLimiter ratelimiter.Config
+	jobs             jobsConfig
+	exports          exportsConfig
+}
+
+type exportsConfig struct {
+	// signingSecret signs the download links
+	signingSecret string
+	// linkExp is how long download links, and the archives, are available
+	linkExp      time.Duration
+	pollInterval time.Duration
+	batchSize    int
+	// lease is how long a claimed export is hidden from other workers
+	lease       time.Duration
+	maxAttempts int
+}
+
+type jobsConfig struct {
+	cleanupInterval time.Duration
+	// unactivatedGracePeriod is how long accounts that were never
+	// activated are kept before being deleted
+	unactivatedGracePeriod time.Duration
+	// accountDeletionGracePeriod is how long users have to cancel the
+	// deletion of their account
+	accountDeletionGracePeriod time.Duration
 }
 
 type redisConfig struct {
@@ -61,8 +118,41 @@ type redisConfig struct {
 }
 
 type authConfig struct {
-	basic basicConfig
-	token tokenConfig
+	basic     basicConfig
+	token     tokenConfig
+	twoFactor twoFactorConfig
+	lockout   lockoutConfig
+	oidc      oidcConfig
+	// password is the policy new passwords must follow
+	password auth.PasswordPolicy
+	// hashing is how new password hashes are made. Older hashes are
+	// upgraded when their user logs in
+	hashing store.PasswordHashing
+}
+
+type oidcConfig struct {
+	// enabled when an issuer is configured
+	issuer       string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+}
+
+type lockoutConfig struct {
+	// maxAttempts failed logins for an account, or maxIPAttempts from an IP,
+	// within window lock them out for the lockout duration
+	maxAttempts   int
+	maxIPAttempts int
+	window        time.Duration
+	lockout       time.Duration
+}
+
+type twoFactorConfig struct {
+	issuer       string
+	challengeExp time.Duration
+	// requiredLevel makes 2FA mandatory for roles at or above this level,
+	// 0 disables the requirement
+	requiredLevel int64
 }
 
 type basicConfig struct {
@@ -71,19 +161,30 @@ type basicConfig struct {
 }
 
 type tokenConfig struct {
-	secret string
-	exp    time.Duration
-	iss    string
+	// alg is HS256, signing with secret, or RS256/EdDSA, signing with the
+	// activeKid key out of the PEM files in keysDir
+	alg        string
+	secret     string
+	keysDir    string
+	activeKid  string
+	exp        time.Duration
+	refreshExp time.Duration
+	iss        string
 }
 
 type application struct {
-	config        config
-	store         store.Storage
-	logger        *zap.SugaredLogger
-	mailer        mailer.Client
-	authenticator auth.Authenticator
-	cacheStorage  cache.Storage
-	rateLimiter   ratelimiter.Limiter
+	config           config
+	store            store.Storage
+	logger           *zap.SugaredLogger
+	mailer           mailer.Client
+	authenticator    auth.Authenticator
+	oidc             oidcProvider
+	cacheStorage     cache.Storage
+	rateLimiter      ratelimiter.Limiter
+	emailRateLimiter ratelimiter.Limiter
+	// wg tracks the work started with background, so shutdown can wait for it
+	wg             sync.WaitGroup
+	sessionTouches sessionThrottle
 }
 
 func (app *application) mount() http.Handler {
@@ -109,6 +210,8 @@ func (app *application) mount() http.Handler {
 
 	r.Use(middleware.Timeout(60 * time.Second))
 
+	r.Get("/.well-known/jwks.json", app.jwksHandler)
+
 	r.Route("/v1", func(r chi.Router) {
 		r.With(app.BasicAuthMiddleware()).Get("/health", app.healthCheckHandler)
 
@@ -119,33 +222,119 @@ func (app *application) mount() http.Handler {
 
 		r.Route("/posts", func(r chi.Router) {
 			r.Use(app.AuthTokenMiddleware())
-			r.Post("/", app.createPostHandler)
+			r.With(app.CheckScopeMiddleware(scopePostsWrite)).Post("/", app.createPostHandler)
 			r.Route("/{postID}", func(r chi.Router) {
 				r.Use(app.postsContextMiddleware)
-				r.Get("/", app.getPostByIdHandler)
-				r.Delete("/", app.CheckPostOwnershipMiddleware("admin", app.deletePostHandler))
-				r.Patch("/", app.CheckPostOwnershipMiddleware("moderator", app.updatePostByIdHandler))
-				r.Post("/comments", app.createCommentToPostHandler)
+				r.With(app.CheckScopeMiddleware(scopePostsRead)).Get("/", app.getPostByIdHandler)
+				r.Group(func(r chi.Router) {
+					r.Use(app.CheckScopeMiddleware(scopePostsWrite))
+					r.Delete("/", app.CheckPostOwnershipMiddleware("admin", app.deletePostHandler))
+					r.Patch("/", app.CheckPostOwnershipMiddleware("moderator", app.updatePostByIdHandler))
+					r.Post("/comments", app.createCommentToPostHandler)
+				})
 			})
 		})
 
 		r.Route("/users", func(r chi.Router) {
 			r.Put("/activate/{token}", app.activateUserHandler)
+			r.Get("/exports/{exportID}/download", app.downloadDataExportHandler)
 			r.Route("/{userID}", func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware())
-				r.Get("/", app.getUserHandler)
-				r.Put("/follow", app.followUserHandler)
-				r.Put("/unfollow", app.unfollowUserHandler)
+				r.Group(func(r chi.Router) {
+					r.Use(app.CheckScopeMiddleware(scopeUsersRead))
+					r.Get("/", app.getUserHandler)
+					r.Get("/followers", app.getFollowersHandler)
+					r.Get("/following", app.getFollowingHandler)
+				})
+				r.Group(func(r chi.Router) {
+					r.Use(app.CheckScopeMiddleware(scopeUsersWrite))
+					r.Put("/follow", app.followUserHandler)
+					r.Put("/unfollow", app.unfollowUserHandler)
+					r.Put("/block", app.blockUserHandler)
+					r.Put("/unblock", app.unblockUserHandler)
+					r.Put("/mute", app.muteUserHandler)
+					r.Put("/unmute", app.unmuteUserHandler)
+					r.Delete("/sessions", app.CheckRoleMiddleware("admin", app.revokeUserSessionsHandler))
+				})
+			})
+			r.Put("/email/confirm/{token}", app.confirmEmailChangeHandler)
+			r.Route("/me", func(r chi.Router) {
+				r.Group(func(r chi.Router) {
+					r.Use(app.AuthTokenMiddleware())
+					r.With(app.CheckScopeMiddleware(scopeUsersRead)).Get("/", app.getProfileHandler)
+					r.With(app.CheckScopeMiddleware(scopeUsersWrite)).Patch("/", app.updateProfileHandler)
+					r.With(app.CheckScopeMiddleware(scopeUsersRead)).Get("/blocks", app.listBlockedHandler)
+					r.With(app.CheckScopeMiddleware(scopeUsersRead)).Get("/mutes", app.listMutedHandler)
+					r.With(app.CheckScopeMiddleware(scopeUsersRead)).Get("/suggestions", app.getSuggestionsHandler)
+				})
+				r.With(app.SessionAuthMiddleware()).Put("/password", app.changePasswordHandler)
+				r.With(app.SessionAuthMiddleware()).Post("/exports", app.requestDataExportHandler)
+				r.Route("/deletion", func(r chi.Router) {
+					r.Use(app.SessionAuthMiddleware())
+					r.Post("/", app.requestAccountDeletionHandler)
+					r.Delete("/", app.cancelAccountDeletionHandler)
+				})
+				r.Route("/follow-requests", func(r chi.Router) {
+					r.Use(app.AuthTokenMiddleware())
+					r.With(app.CheckScopeMiddleware(scopeUsersRead)).Get("/", app.listFollowRequestsHandler)
+					r.Group(func(r chi.Router) {
+						r.Use(app.CheckScopeMiddleware(scopeUsersWrite))
+						r.Put("/{requesterID}/approve", app.approveFollowRequestHandler)
+						r.Put("/{requesterID}/reject", app.rejectFollowRequestHandler)
+					})
+				})
+				r.Route("/tokens", func(r chi.Router) {
+					r.Use(app.SessionAuthMiddleware())
+					r.Get("/", app.listAccessTokensHandler)
+					r.Post("/", app.createAccessTokenHandler)
+					r.Delete("/{tokenID}", app.revokeAccessTokenHandler)
+				})
+				r.Route("/sessions", func(r chi.Router) {
+					r.Use(app.SessionAuthMiddleware())
+					r.Get("/", app.listSessionsHandler)
+					r.Delete("/{sessionID}", app.revokeSessionHandler)
+				})
 			})
 			r.Group(func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware())
-				r.Get("/feed", app.getUserFeedHandler)
+				r.With(app.CheckScopeMiddleware(scopePostsRead)).Get("/feed", app.getUserFeedHandler)
+				r.With(app.CheckScopeMiddleware(scopeUsersRead)).Get("/search", app.searchUsersHandler)
 			})
 		})
 
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware())
+			r.With(app.CheckScopeMiddleware(scopeUsersRead)).Get("/password-hashes", app.CheckRoleMiddleware("admin", app.passwordHashReportHandler))
+		})
+
 		r.Route("/auth", func(r chi.Router) {
 			r.Post("/user", app.registerUserHandler)
 			r.Post("/token", app.createTokenHandler)
+			r.Post("/refresh", app.refreshTokenHandler)
+			r.With(app.TwoFactorSetupMiddleware()).Post("/logout", app.logoutHandler)
+			r.Post("/activation/resend", app.resendActivationHandler)
+			r.Post("/password/forgot", app.forgotPasswordHandler)
+			r.Post("/password/reset", app.resetPasswordHandler)
+			r.Post("/magic-link", app.requestMagicLinkHandler)
+			r.Post("/magic-link/token", app.magicLinkTokenHandler)
+
+			r.Route("/2fa", func(r chi.Router) {
+				r.Post("/challenge", app.twoFactorChallengeHandler)
+				r.Group(func(r chi.Router) {
+					r.Use(app.TwoFactorSetupMiddleware())
+					r.Post("/enroll", app.enrollTwoFactorHandler)
+					r.Post("/verify", app.verifyTwoFactorHandler)
+					r.Delete("/", app.disableTwoFactorHandler)
+				})
+			})
+
+			if app.oidc != nil {
+				r.Route("/oidc", func(r chi.Router) {
+					r.Get("/login", app.oidcLoginHandler)
+					r.Get("/callback", app.oidcCallbackHandler)
+					r.Post("/token", app.oidcTokenHandler)
+				})
+			}
 		})
 	})
 	return r
@@ -168,6 +357,9 @@ func (app *application) run(mux http.Handler) error {
 
 	shutdown := make(chan error)
 
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	app.startJobs(jobsCtx)
+
 	go func() {
 		quit := make(chan os.Signal, 1)
 		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -178,7 +370,12 @@ func (app *application) run(mux http.Handler) error {
 
 		app.logger.Infow("signal caught", "signal", s.String())
 
-		shutdown <- srv.Shutdown(ctx)
+		stopJobs()
+
+		err := srv.Shutdown(ctx)
+		app.wg.Wait()
+
+		shutdown <- err
 	}()
 
 	app.logger.Infow("Server has started", "addr", app.config.addr, "env", app.config.env)
@@ -1,12 +1,16 @@
 package main
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/alejandro-cardenas-g/social/internal/auth"
 	"github.com/alejandro-cardenas-g/social/internal/mailer"
 	"github.com/alejandro-cardenas-g/social/internal/store"
 	"github.com/golang-jwt/jwt/v5"
@@ -16,7 +20,8 @@ import (
 type RegisterUserPayload struct {
 	Username string `json:"username" validate:"required,max=100"`
 	Email    string `json:"email" validate:"required,email,max=255"`
-	Password string `json:"password" validate:"required,min=5,max=15"`
+	Password string `json:"password" validate:"required,max=255"`
+	Language string `json:"language" validate:"omitempty,language"`
 }
 
 // registerUserHandler godoc
@@ -44,15 +49,21 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	if err := app.config.auth.password.Validate(payload.Password); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
 	user := &store.User{
 		Username: payload.Username,
 		Email:    payload.Email,
+		Language: payload.Language,
 		Role: store.Role{
 			Name: "user",
 		},
 	}
 
-	if err := user.Password.Set(payload.Password); err != nil {
+	if err := user.Password.Set(payload.Password, app.config.auth.hashing); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
@@ -61,10 +72,13 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 
 	plainToken := uuid.New().String()
 
-	hash := sha256.Sum256([]byte(plainToken))
-	hashToken := hex.EncodeToString(hash[:]) //hash[:] converts byte[n] to byte[]
+	email, err := newOutboxEmail(mailer.UserWelcomeTemplate, user, app.welcomeEmailVars(user, plainToken))
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
 
-	err := app.store.Users.CreateAndInvite(ctx, user, hashToken, app.config.mail.exp)
+	err = app.store.Users.CreateAndInvite(ctx, user, hashToken(plainToken), app.config.mail.exp, email)
 
 	if err != nil {
 		switch err {
@@ -76,39 +90,92 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	activationUrl := fmt.Sprintf("%s/confirm/%s", app.config.frontendURL, plainToken)
+	if err := app.jsonResponse(w, http.StatusCreated, nil); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
 
-	isProdEnv := app.config.env == "production"
+func (app *application) welcomeEmailVars(user *store.User, plainToken string) any {
+	activationUrl := fmt.Sprintf("%s/confirm/%s", app.config.frontendURL, plainToken)
 
-	vars := struct {
+	return struct {
 		Username      string
 		ActivationURL string
 	}{
 		Username:      user.Username,
 		ActivationURL: activationUrl,
 	}
+}
 
-	status, err := app.mailer.Send(mailer.UserWelcomeTemplate, user.Username, user.Email, vars, !isProdEnv)
+type ResendActivationPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
 
-	if err != nil {
-		app.logger.Errorw("Error sending welcome email", "error", err)
+// resendActivationHandler godoc
+//
+//	@Summary		Resends the activation email
+//	@Description	Issues a new invitation token and emails it if the email belongs to an account pending activation
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ResendActivationPayload	true	"User email"
+//	@Success		202		{string}	string					"Activation email requested"
+//	@Failure		400		{object}	error
+//	@Failure		429		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/auth/activation/resend [post]
+func (app *application) resendActivationHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ResendActivationPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
 
-		if err := app.store.Users.Delete(ctx, user.ID); err != nil {
-			app.logger.Errorw("Error deleting user", "error", err)
-		}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
 
-		app.internalServerError(w, r, err)
+	if allow, retryAfter := app.emailRateLimiter.Allow("activation:" + strings.ToLower(payload.Email)); !allow {
+		app.rateLimitExceededError(w, r, retryAfter.String())
 		return
 	}
 
-	app.logger.Info("Email sent", "status code", status)
+	if err := app.resendActivation(r.Context(), payload.Email); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			// do not disclose which emails are registered or already active
+			app.jsonResponse(w, http.StatusAccepted, nil)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
 
-	if err := app.jsonResponse(w, http.StatusCreated, nil); err != nil {
+	if err := app.jsonResponse(w, http.StatusAccepted, nil); err != nil {
 		app.internalServerError(w, r, err)
-		return
 	}
 }
 
+// resendActivation issues a new invitation token to the inactive user of
+// email and queues the welcome email with its link in the same transaction.
+func (app *application) resendActivation(ctx context.Context, email string) error {
+	user, err := app.store.Users.GetPendingActivation(ctx, email)
+	if err != nil {
+		return err
+	}
+
+	plainToken := uuid.New().String()
+
+	welcome, err := newOutboxEmail(mailer.UserWelcomeTemplate, user, app.welcomeEmailVars(user, plainToken))
+	if err != nil {
+		return err
+	}
+
+	return app.store.Users.RotateInvitation(ctx, user.ID, hashToken(plainToken), app.config.mail.exp, welcome)
+}
+
 type CreateUserTokenPayload struct {
 	Email    string `json:"email" validate:"required,email,max=255"`
 	Password string `json:"password" validate:"required,min=3,max=72"`
@@ -122,9 +189,11 @@ type CreateUserTokenPayload struct {
 //	@Accept			json
 //	@Produce		json
 //	@Param			payload	body		CreateUserTokenPayload	true	"User credentials"
-//	@Success		200		{string}	string					"Token"
+//	@Success		201		{object}	TokenResponse
+//	@Success		202		{object}	TwoFactorChallengeResponse
 //	@Failure		400		{object}	error
 //	@Failure		401		{object}	error
+//	@Failure		429		{object}	error
 //	@Failure		500		{object}	error
 //	@Router			/auth/token [post]
 func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Request) {
@@ -139,11 +208,24 @@ func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	user, err := app.store.Users.GetByEmail(r.Context(), payload.Email)
+	ctx := r.Context()
+
+	lockedFor, err := app.loginLockedFor(ctx, r, accountAttemptsKey(payload.Email))
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if lockedFor > 0 {
+		app.loginLockedError(w, r, strconv.Itoa(int(lockedFor.Seconds())))
+		return
+	}
+
+	user, err := app.store.Users.GetByEmail(ctx, payload.Email)
 	if err != nil {
 		switch err {
 		case store.ErrNotFound:
-			app.unauthorizedError(w, r, err)
+			app.failedLogin(w, r, accountAttemptsKey(payload.Email), nil, err)
 		default:
 			app.internalServerError(w, r, err)
 		}
@@ -151,12 +233,183 @@ func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	if err := user.Password.Compare(payload.Password); err != nil {
-		app.unauthorizedError(w, r, err)
+		app.failedLogin(w, r, accountAttemptsKey(payload.Email), user, err)
 		return
 	}
 
+	if user.Password.NeedsRehash(app.config.auth.hashing) {
+		// the login goes on with the old hash if the upgrade fails
+		if err := app.store.Users.RehashPassword(ctx, user, payload.Password); err != nil {
+			app.logger.Errorw("failed to rehash password", "user", user.ID, "error", err)
+		}
+	}
+
+	app.completeLogin(w, r, user)
+}
+
+// completeLogin answers a login whose password (or equivalent first factor)
+// was already verified. Users with 2FA enabled get a challenge to solve
+// instead of the tokens, and their failed attempts are kept until they
+// solve it.
+func (app *application) completeLogin(w http.ResponseWriter, r *http.Request, user *store.User) {
+	if user.TwoFactorEnabled {
+		challenge, err := app.generateChallengeToken(user.ID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		res := &TwoFactorChallengeResponse{
+			TwoFactorRequired: true,
+			ChallengeToken:    challenge,
+			ExpiresIn:         int64(app.config.auth.twoFactor.challengeExp.Seconds()),
+		}
+
+		if err := app.jsonResponse(w, http.StatusAccepted, res); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.resetLoginFailures(r.Context(), user); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	tokens, err := app.issueTokens(r, user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, tokens); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+const (
+	accessTokenType    = "access"
+	challengeTokenType = "2fa_challenge"
+)
+
+type RefreshTokenPayload struct {
+	RefreshToken string `json:"refresh_token" validate:"required,max=255"`
+}
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// refreshTokenHandler godoc
+//
+//	@Summary		Refreshes a token
+//	@Description	Exchanges a refresh token for a new access and refresh token pair
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		RefreshTokenPayload	true	"Refresh token"
+//	@Success		201		{object}	TokenResponse
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/auth/refresh [post]
+func (app *application) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var payload RefreshTokenPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	plainToken := uuid.New().String()
+
+	next := &store.RefreshToken{
+		Token:  hashToken(plainToken),
+		Expiry: time.Now().Add(app.config.auth.token.refreshExp),
+	}
+
+	ctx := r.Context()
+
+	if err := app.store.RefreshTokens.Rotate(ctx, hashToken(payload.RefreshToken), next); err != nil {
+		switch err {
+		case store.ErrTokenReused:
+			app.logger.Warnw("refresh token reuse detected, family revoked", "path", r.URL.Path)
+			app.unauthorizedError(w, r, err)
+		case store.ErrNotFound:
+			app.unauthorizedError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	accessToken, err := app.generateAccessToken(next.UserID, next.FamilyID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	tokens := &TokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: plainToken,
+		ExpiresIn:    int64(app.config.auth.token.exp.Seconds()),
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, tokens); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// issueTokens starts a new session for the user on the device of the
+// request and returns its first refresh token together with an access token.
+func (app *application) issueTokens(r *http.Request, user *store.User) (*TokenResponse, error) {
+	plainToken := uuid.New().String()
+
+	refreshToken := &store.RefreshToken{
+		Token:    hashToken(plainToken),
+		UserID:   user.ID,
+		FamilyID: uuid.New().String(),
+		Expiry:   time.Now().Add(app.config.auth.token.refreshExp),
+	}
+
+	session := &store.Session{
+		ID:        refreshToken.FamilyID,
+		UserID:    user.ID,
+		UserAgent: truncate(r.UserAgent(), 512),
+		IP:        clientIP(r),
+		Expiry:    refreshToken.Expiry,
+	}
+
+	if err := app.store.Sessions.Create(r.Context(), session, refreshToken); err != nil {
+		return nil, err
+	}
+
+	accessToken, err := app.generateAccessToken(user.ID, refreshToken.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: plainToken,
+		ExpiresIn:    int64(app.config.auth.token.exp.Seconds()),
+	}, nil
+}
+
+// generateAccessToken signs an access token bound to the refresh token
+// family it was issued with, so logging out can revoke both.
+func (app *application) generateAccessToken(userID int64, sessionID string) (string, error) {
 	claims := jwt.MapClaims{
-		"sub": user.ID,
+		"jti": uuid.New().String(),
+		"typ": accessTokenType,
+		"sid": sessionID,
+		"sub": userID,
 		"exp": time.Now().Add(app.config.auth.token.exp).Unix(),
 		"iat": time.Now().Unix(),
 		"nbf": time.Now().Unix(),
@@ -164,13 +417,166 @@ func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 		"aud": app.config.auth.token.iss,
 	}
 
-	token, err := app.authenticator.GenerateToken(claims)
+	return app.authenticator.GenerateToken(claims)
+}
+
+// generateChallengeToken signs the short-lived token that proves the
+// password step of a 2FA login succeeded.
+func (app *application) generateChallengeToken(userID int64) (string, error) {
+	claims := jwt.MapClaims{
+		"jti": uuid.New().String(),
+		"typ": challengeTokenType,
+		"sub": userID,
+		"exp": time.Now().Add(app.config.auth.twoFactor.challengeExp).Unix(),
+		"iat": time.Now().Unix(),
+		"nbf": time.Now().Unix(),
+		"iss": app.config.auth.token.iss,
+		"aud": app.config.auth.token.iss,
+	}
+
+	return app.authenticator.GenerateToken(claims)
+}
+
+func hashToken(plainToken string) string {
+	hash := sha256.Sum256([]byte(plainToken))
+	return hex.EncodeToString(hash[:])
+}
+
+// logoutHandler godoc
+//
+//	@Summary		Logs out
+//	@Description	Revokes the current access token and its refresh token family
+//	@Tags			auth
+//	@Produce		json
+//	@Success		204	{string}	string	"Logged out"
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/auth/logout [post]
+func (app *application) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	claims := getClaimsFromCtx(r)
+	ctx := r.Context()
+
+	exp, err := claims.GetExpirationTime()
 	if err != nil {
+		app.unauthorizedError(w, r, err)
+		return
+	}
+
+	jti, _ := claims["jti"].(string)
+
+	if err := app.tokenRevocations().Revoke(ctx, jti, exp.Time); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	if err := app.jsonResponse(w, http.StatusCreated, token); err != nil {
+	if sid, _ := claims["sid"].(string); sid != "" {
+		if err := app.store.RefreshTokens.RevokeFamily(ctx, sid); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		// access tokens refreshed earlier in the session are still valid
+		if err := app.tokenRevocations().RevokeSession(ctx, sid, time.Now().Add(app.config.auth.token.exp)); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type revocationStore interface {
+	Revoke(ctx context.Context, jti string, exp time.Time) error
+	RevokeUser(ctx context.Context, userID int64, exp time.Time) error
+	RevokeSession(ctx context.Context, sessionID string, exp time.Time) error
+	IsRevoked(ctx context.Context, jti string, sessionID string, userID int64, issuedAt time.Time) (bool, error)
+}
+
+func (app *application) tokenRevocations() revocationStore {
+	if app.config.redisCfg.enabled {
+		return app.cacheStorage.RevokedTokens
+	}
+	return app.store.RevokedTokens
+}
+
+func (app *application) isTokenRevoked(ctx context.Context, claims jwt.MapClaims, userID int64) (bool, error) {
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		// tokens without an id cannot be revoked, so they are not accepted
+		return true, nil
+	}
+
+	issuedAt, err := claims.GetIssuedAt()
+	if err != nil || issuedAt == nil {
+		return true, nil
+	}
+
+	sid, _ := claims["sid"].(string)
+
+	return app.tokenRevocations().IsRevoked(ctx, jti, sid, userID, issuedAt.Time)
+}
+
+// revokeUserSessions invalidates every access and refresh token issued to
+// the user so far, and their personal access tokens. User revocations only
+// cover tokens issued in an earlier second, so the sessions are revoked as
+// well to catch the rest.
+func (app *application) revokeUserSessions(ctx context.Context, userID int64) error {
+	if err := app.store.AccessTokens.DeleteByUser(ctx, userID); err != nil {
+		return err
+	}
+
+	sessionIDs, err := app.store.RefreshTokens.RevokeByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	exp := time.Now().Add(app.config.auth.token.exp)
+	if err := app.tokenRevocations().RevokeUser(ctx, userID, exp); err != nil {
+		return err
+	}
+
+	// the database store reads session revocations from the sessions table
+	if !app.config.redisCfg.enabled {
+		return nil
+	}
+
+	for _, sessionID := range sessionIDs {
+		if err := app.tokenRevocations().RevokeSession(ctx, sessionID, exp); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type claimsKey string
+
+const claimsCtx claimsKey = "claims"
+
+func getClaimsFromCtx(r *http.Request) jwt.MapClaims {
+	claims := r.Context().Value(claimsCtx).(jwt.MapClaims)
+	return claims
+}
+
+// jwksHandler godoc
+//
+//	@Summary		Publishes the token signing keys
+//	@Description	Returns the public keys tokens are signed with as a JSON Web Key Set. Empty when tokens use HS256
+//	@Tags			auth
+//	@Produce		json
+//	@Success		200	{object}	auth.JWKSet
+//	@Router			/.well-known/jwks.json [get]
+func (app *application) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	set := auth.JWKSet{Keys: []auth.JWK{}}
+
+	if publisher, ok := app.authenticator.(auth.KeyPublisher); ok {
+		set = publisher.JWKS()
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+
+	if err := writeJSON(w, http.StatusOK, set); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
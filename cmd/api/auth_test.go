@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alejandro-cardenas-g/social/internal/auth"
+	"github.com/alejandro-cardenas-g/social/internal/mailer"
+	"github.com/alejandro-cardenas-g/social/internal/store"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRefreshToken(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	refreshTokens := app.store.RefreshTokens.(*store.MockRefreshTokensStore)
+
+	refresh := func(t *testing.T, token string) int {
+		body := strings.NewReader(`{"refresh_token":"` + token + `"}`)
+		req, err := http.NewRequest(http.MethodPost, "/v1/auth/refresh", body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should accept a rotated token only once", func(t *testing.T) {
+		refreshTokens.On("Rotate", hashToken("rotated")).Return(nil).Once()
+		refreshTokens.On("Rotate", hashToken("rotated")).Return(store.ErrTokenReused).Once()
+
+		checkResponseCode(t, http.StatusCreated, refresh(t, "rotated"))
+		checkResponseCode(t, http.StatusUnauthorized, refresh(t, "rotated"))
+	})
+
+	t.Run("should reject an expired token", func(t *testing.T) {
+		refreshTokens.On("Rotate", hashToken("expired")).Return(store.ErrNotFound).Once()
+
+		checkResponseCode(t, http.StatusUnauthorized, refresh(t, "expired"))
+	})
+
+	refreshTokens.AssertExpectations(t)
+}
+
+func TestLoginLockout(t *testing.T) {
+	cfg := config{
+		auth: authConfig{
+			twoFactor: twoFactorConfig{challengeExp: time.Minute},
+			lockout:   lockoutConfig{maxAttempts: 2, maxIPAttempts: 50, lockout: time.Minute},
+			hashing:   store.DefaultPasswordHashing(),
+		},
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := &store.User{ID: 1, Email: "ana@example.com", TwoFactorEnabled: true}
+	if err := user.Password.Set("correct horse", store.DefaultPasswordHashing()); err != nil {
+		t.Fatal(err)
+	}
+
+	newApp := func(t *testing.T) (http.Handler, *store.MockUserStore) {
+		app := newTestApplication(t, cfg)
+		users := app.store.Users.(*store.MockUserStore)
+		users.On("GetByEmail", user.Email).Return(user, nil)
+		return app.mount(), users
+	}
+
+	post := func(t *testing.T, mux http.Handler, path, body string) (int, map[string]any) {
+		req, err := http.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+
+		var res struct {
+			Data map[string]any `json:"data"`
+		}
+		_ = json.NewDecoder(rr.Body).Decode(&res)
+
+		return rr.Code, res.Data
+	}
+
+	login := func(t *testing.T, mux http.Handler, password string) (int, string) {
+		code, data := post(t, mux, "/v1/auth/token", `{"email":"ana@example.com","password":"`+password+`"}`)
+		challenge, _ := data["challenge_token"].(string)
+		return code, challenge
+	}
+
+	solve := func(t *testing.T, mux http.Handler, challenge, code string) int {
+		status, _ := post(t, mux, "/v1/auth/2fa/challenge", `{"challenge_token":"`+challenge+`","code":"`+code+`"}`)
+		return status
+	}
+
+	t.Run("should keep password failures until the second factor is solved", func(t *testing.T) {
+		mux, users := newApp(t)
+
+		code, _ := login(t, mux, "wrong horse")
+		checkResponseCode(t, http.StatusUnauthorized, code)
+
+		code, _ = login(t, mux, "correct horse")
+		checkResponseCode(t, http.StatusAccepted, code)
+
+		code, _ = login(t, mux, "wrong horse")
+		checkResponseCode(t, http.StatusUnauthorized, code)
+
+		code, _ = login(t, mux, "correct horse")
+		checkResponseCode(t, http.StatusTooManyRequests, code)
+
+		users.AssertExpectations(t)
+	})
+
+	t.Run("should lock the second factor after too many wrong codes", func(t *testing.T) {
+		mux, users := newApp(t)
+		users.On("GetByID", user.ID).Return(user, nil)
+		users.On("GetTOTPSecret", user.ID).Return(secret, nil)
+
+		for range cfg.auth.lockout.maxAttempts {
+			code, challenge := login(t, mux, "correct horse")
+			checkResponseCode(t, http.StatusAccepted, code)
+			checkResponseCode(t, http.StatusUnauthorized, solve(t, mux, challenge, "000000"))
+		}
+
+		code, challenge := login(t, mux, "correct horse")
+		checkResponseCode(t, http.StatusAccepted, code)
+
+		totp, err := auth.GenerateTOTPCode(secret, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkResponseCode(t, http.StatusTooManyRequests, solve(t, mux, challenge, totp))
+
+		users.AssertExpectations(t)
+	})
+}
+
+func TestResendActivation(t *testing.T) {
+	cfg := config{
+		mail: mailConfig{exp: time.Hour},
+	}
+	app := newTestApplication(t, cfg)
+	mux := app.mount()
+
+	users := app.store.Users.(*store.MockUserStore)
+
+	resend := func(t *testing.T, email string) int {
+		req, err := http.NewRequest(http.MethodPost, "/v1/auth/activation/resend", strings.NewReader(`{"email":"`+email+`"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should rotate the invitation along with its email", func(t *testing.T) {
+		welcome := mock.MatchedBy(func(email *store.OutboxEmail) bool {
+			return email.Template == mailer.UserWelcomeTemplate && email.Email == "ana@example.com"
+		})
+
+		users.On("GetPendingActivation", "ana@example.com").Return(&store.User{ID: 7, Username: "ana", Email: "ana@example.com"}, nil).Once()
+		users.On("RotateInvitation", int64(7), mock.Anything, welcome).Return(nil).Once()
+
+		checkResponseCode(t, http.StatusAccepted, resend(t, "ana@example.com"))
+	})
+
+	t.Run("should respond the same to an unknown or active email", func(t *testing.T) {
+		users.On("GetPendingActivation", "nobody@example.com").Return(nil, store.ErrNotFound).Once()
+
+		checkResponseCode(t, http.StatusAccepted, resend(t, "nobody@example.com"))
+	})
+
+	t.Run("should report a failed rotation", func(t *testing.T) {
+		users.On("GetPendingActivation", "ben@example.com").Return(&store.User{ID: 8, Email: "ben@example.com"}, nil).Once()
+		users.On("RotateInvitation", int64(8), mock.Anything, mock.Anything).Return(errors.New("connection refused")).Once()
+
+		checkResponseCode(t, http.StatusInternalServerError, resend(t, "ben@example.com"))
+	})
+
+	users.AssertExpectations(t)
+}
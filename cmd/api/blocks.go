@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/alejandro-cardenas-g/social/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+// BlockUser godoc
+//
+//	@Summary		Blocks a user
+//	@Description	Blocks a user by ID. The follows between both users are removed, the blocked user cannot follow the blocker, see their posts or comment on them
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User blocked"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/block [put]
+func (app *application) blockUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.updateRelationship(w, r, func(ctx context.Context, userID, otherID int64) error {
+		if err := app.store.Blocks.Block(ctx, userID, otherID); err != nil {
+			return err
+		}
+
+		// the follows between both users were removed
+		return app.invalidateFollows(ctx, userID, otherID)
+	})
+}
+
+// UnblockUser godoc
+//
+//	@Summary		Unblocks a user
+//	@Description	Unblocks a user by ID. The follows removed by the block are not restored
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User unblocked"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/unblock [put]
+func (app *application) unblockUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.updateRelationship(w, r, app.store.Blocks.Unblock)
+}
+
+// MuteUser godoc
+//
+//	@Summary		Mutes a user
+//	@Description	Mutes a user by ID. Their posts and comments are left out of the muter's feed and of the comments of posts
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User muted"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/mute [put]
+func (app *application) muteUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.updateRelationship(w, r, app.store.Mutes.Mute)
+}
+
+// UnmuteUser godoc
+//
+//	@Summary		Unmutes a user
+//	@Description	Unmutes a user by ID
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User unmuted"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/unmute [put]
+func (app *application) unmuteUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.updateRelationship(w, r, app.store.Mutes.Unmute)
+}
+
+// updateRelationship applies update between the authenticated user and the
+// user of the path.
+func (app *application) updateRelationship(w http.ResponseWriter, r *http.Request, update func(ctx context.Context, userID, otherID int64) error) {
+	otherID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	if user.ID == otherID {
+		app.conflictError(w, r, errors.New("users cannot block or mute themselves"))
+		return
+	}
+
+	if err := update(r.Context(), user.ID, otherID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+		case store.ErrConflict:
+			app.conflictError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listBlockedHandler godoc
+//
+//	@Summary		Lists the blocked users
+//	@Description	Lists the users the authenticated user blocked, most recent first
+//	@Tags			users
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	[]store.RelatedUser
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/blocks [get]
+func (app *application) listBlockedHandler(w http.ResponseWriter, r *http.Request) {
+	app.listRelatedUsers(w, r, app.store.Blocks.GetBlocked)
+}
+
+// listMutedHandler godoc
+//
+//	@Summary		Lists the muted users
+//	@Description	Lists the users the authenticated user muted, most recent first
+//	@Tags			users
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	[]store.RelatedUser
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/mutes [get]
+func (app *application) listMutedHandler(w http.ResponseWriter, r *http.Request) {
+	app.listRelatedUsers(w, r, app.store.Mutes.GetMuted)
+}
+
+func (app *application) listRelatedUsers(w http.ResponseWriter, r *http.Request, list func(context.Context, int64, store.PaginatedQuery) ([]store.RelatedUser, error)) {
+	pq, err := store.PaginatedQuery{Limit: 20, Offset: 0}.Parse(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(pq); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	users, err := list(r.Context(), getUserFromCtx(r).ID, pq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, users); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -7,11 +7,28 @@ import (
 )
 
 type CreateCommentToPostPayload struct {
-	Content string `json:"content"`
+	Content string `json:"content" validate:"required,max=1000"`
 }
 
+// CreateComment godoc
+//
+//	@Summary		Comments a post
+//	@Description	Adds a comment to a post. Users cannot comment on posts of users who blocked them or whom they blocked
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int							true	"Post ID"
+//	@Param			payload	body		CreateCommentToPostPayload	true	"Comment payload"
+//	@Success		200		{object}	store.Comment
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments [post]
 func (api *application) createCommentToPostHandler(w http.ResponseWriter, r *http.Request) {
 	post := getPostFromCtx(r)
+	user := getUserFromCtx(r)
 
 	payload := &CreateCommentToPostPayload{}
 
@@ -25,13 +42,26 @@ func (api *application) createCommentToPostHandler(w http.ResponseWriter, r *htt
 		return
 	}
 
+	ctx := r.Context()
+
+	blocked, err := api.store.Blocks.ExistsBetween(ctx, user.ID, post.UserId)
+	if err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+
+	if blocked {
+		api.forbiddenError(w, r)
+		return
+	}
+
 	comment := &store.Comment{
-		UserID:  1,
+		UserID:  user.ID,
 		PostID:  post.ID,
 		Content: payload.Content,
 	}
 
-	if err := api.store.Comments.Create(r.Context(), comment); err != nil {
+	if err := api.store.Comments.Create(ctx, comment); err != nil {
 		api.internalServerError(w, r, err)
 		return
 	}
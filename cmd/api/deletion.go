@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/alejandro-cardenas-g/social/internal/mailer"
+	"github.com/alejandro-cardenas-g/social/internal/store"
+)
+
+type AccountDeletionResponse struct {
+	DeletionScheduledAt time.Time `json:"deletion_scheduled_at"`
+}
+
+// requestAccountDeletionHandler godoc
+//
+//	@Summary		Schedules the deletion of the user's account
+//	@Description	Schedules the account to be deleted once the grace period is over, along with the user's posts, comments and follows. The user is notified by email and can cancel it until then
+//	@Tags			users
+//	@Produce		json
+//	@Success		202	{object}	AccountDeletionResponse
+//	@Failure		401	{object}	error
+//	@Failure		409	{object}	error	"Deletion already scheduled"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/deletion [post]
+func (app *application) requestAccountDeletionHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	ctx := r.Context()
+
+	at := time.Now().Add(app.config.jobs.accountDeletionGracePeriod).Truncate(time.Second)
+
+	vars := struct {
+		Username     string
+		DeletionDate string
+		CancelURL    string
+	}{
+		Username:     user.Username,
+		DeletionDate: at.UTC().Format(time.DateTime + " MST"),
+		CancelURL:    fmt.Sprintf("%s/settings/account", app.config.frontendURL),
+	}
+
+	email, err := newOutboxEmail(mailer.AccountDeletionTemplate, user, vars)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.Users.ScheduleDeletion(ctx, user.ID, at, email); err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.invalidateUser(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusAccepted, AccountDeletionResponse{DeletionScheduledAt: at}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// cancelAccountDeletionHandler godoc
+//
+//	@Summary		Cancels the deletion of the user's account
+//	@Description	Keeps the account whose deletion was scheduled
+//	@Tags			users
+//	@Produce		json
+//	@Success		204	{string}	string	"Deletion cancelled"
+//	@Failure		401	{object}	error
+//	@Failure		404	{object}	error	"No deletion scheduled"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/deletion [delete]
+func (app *application) cancelAccountDeletionHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	ctx := r.Context()
+
+	if err := app.store.Users.CancelDeletion(ctx, user.ID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.invalidateUser(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
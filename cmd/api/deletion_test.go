@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alejandro-cardenas-g/social/internal/mailer"
+	"github.com/alejandro-cardenas-g/social/internal/store"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAccountDeletion(t *testing.T) {
+	cfg := config{
+		jobs: jobsConfig{
+			accountDeletionGracePeriod: time.Hour * 24 * 30,
+		},
+	}
+	app := newTestApplication(t, cfg)
+	users := mockAuthenticatedUser(app, &store.User{ID: mockUserID, Username: "ana", Email: "ana@example.com"})
+	mux := app.mount()
+
+	request := func(t *testing.T, method string) int {
+		return authenticatedRequest(t, app, mux, method, "/v1/users/me/deletion", nil).Code
+	}
+
+	inGracePeriod := mock.MatchedBy(func(at time.Time) bool {
+		return time.Until(at) > cfg.jobs.accountDeletionGracePeriod-time.Minute
+	})
+	deletionEmail := mock.MatchedBy(func(email *store.OutboxEmail) bool {
+		return email.Template == mailer.AccountDeletionTemplate
+	})
+
+	t.Run("should schedule the deletion", func(t *testing.T) {
+		users.On("ScheduleDeletion", mockUserID, inGracePeriod, deletionEmail).Return(nil).Once()
+
+		checkResponseCode(t, http.StatusAccepted, request(t, http.MethodPost))
+	})
+
+	t.Run("should not schedule the deletion twice", func(t *testing.T) {
+		users.On("ScheduleDeletion", mockUserID, inGracePeriod, deletionEmail).Return(store.ErrConflict).Once()
+
+		checkResponseCode(t, http.StatusConflict, request(t, http.MethodPost))
+	})
+
+	t.Run("should cancel the deletion", func(t *testing.T) {
+		users.On("CancelDeletion", mockUserID).Return(nil).Once()
+
+		checkResponseCode(t, http.StatusNoContent, request(t, http.MethodDelete))
+	})
+
+	t.Run("should not cancel a deletion that is not scheduled", func(t *testing.T) {
+		users.On("CancelDeletion", mockUserID).Return(store.ErrNotFound).Once()
+
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodDelete))
+	})
+
+	users.AssertExpectations(t)
+}
@@ -40,6 +40,11 @@ func (app *application) forbiddenError(w http.ResponseWriter, r *http.Request) {
 	writeJSONError(w, http.StatusForbidden, "forbidden")
 }
 
+func (app *application) twoFactorRequiredError(w http.ResponseWriter, r *http.Request) {
+	app.logger.Warnw("two-factor enrollment required", "method", r.Method, "path", r.URL.Path)
+	writeJSONError(w, http.StatusForbidden, "two-factor authentication must be enabled for this account")
+}
+
 func (app *application) rateLimitExceededError(w http.ResponseWriter, r *http.Request, retryAfter string) {
 	app.logger.Warnw("rate limit exceeded", "method", r.Method, "path", r.URL.Path)
 
@@ -47,3 +52,11 @@ func (app *application) rateLimitExceededError(w http.ResponseWriter, r *http.Re
 
 	writeJSONError(w, http.StatusTooManyRequests, "rate limit exceeded, retry after: "+retryAfter)
 }
+
+func (app *application) loginLockedError(w http.ResponseWriter, r *http.Request, retryAfter string) {
+	app.logger.Warnw("login locked", "method", r.Method, "path", r.URL.Path)
+
+	w.Header().Set("Retry-After", retryAfter)
+
+	writeJSONError(w, http.StatusTooManyRequests, "too many failed login attempts, retry after: "+retryAfter)
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alejandro-cardenas-g/social/internal/mailer"
+	"github.com/alejandro-cardenas-g/social/internal/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// requestDataExportHandler godoc
+//
+//	@Summary		Exports the user's personal data
+//	@Description	Starts building a ZIP of JSON files with the user's profile, posts, comments, followers, following and sessions. The user gets an email with a short-lived download link once it is ready
+//	@Tags			users
+//	@Produce		json
+//	@Success		202	{object}	store.DataExport
+//	@Failure		401	{object}	error
+//	@Failure		409	{object}	error	"Export already in progress"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/exports [post]
+func (app *application) requestDataExportHandler(w http.ResponseWriter, r *http.Request) {
+	export, err := app.store.Exports.Create(r.Context(), getUserFromCtx(r).ID)
+	if err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusAccepted, export); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// downloadDataExportHandler godoc
+//
+//	@Summary		Downloads a data export
+//	@Description	Downloads the ZIP of a data export through the signed link emailed to the user
+//	@Tags			users
+//	@Produce		application/zip
+//	@Param			exportID	path		int		true	"Export ID"
+//	@Param			token		query		string	true	"Link token"
+//	@Param			expires		query		int		true	"Link expiry, as a Unix time"
+//	@Param			signature	query		string	true	"Link signature"
+//	@Success		200			{file}		file
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error	"Invalid or expired link"
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Router			/users/exports/{exportID}/download [get]
+func (app *application) downloadDataExportHandler(w http.ResponseWriter, r *http.Request) {
+	exportID, err := strconv.ParseInt(chi.URLParam(r, "exportID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	qs := r.URL.Query()
+
+	expires, err := strconv.ParseInt(qs.Get("expires"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if !app.validExportSignature(exportID, expires, qs.Get("signature")) || time.Now().Unix() > expires {
+		app.forbiddenError(w, r)
+		return
+	}
+
+	token := qs.Get("token")
+	if token == "" {
+		app.forbiddenError(w, r)
+		return
+	}
+
+	archive, err := app.store.Exports.GetArchive(r.Context(), exportID, hashToken(token))
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="socialposts-export-%d.zip"`, exportID))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(archive); err != nil {
+		app.logger.Warnw("data export download interrupted", "export", exportID, "error", err)
+	}
+}
+
+// signExport returns the signature of the download link of an export that
+// expires at the given Unix time.
+func (app *application) signExport(exportID, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(app.config.exports.signingSecret))
+	fmt.Fprintf(mac, "%d:%d", exportID, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (app *application) validExportSignature(exportID, expires int64, signature string) bool {
+	decoded, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	expected, _ := hex.DecodeString(app.signExport(exportID, expires))
+	return hmac.Equal(decoded, expected)
+}
+
+// exportDownloadURL returns the signed download link of an export. The
+// plain token is only sent in the link, the export keeps its hash.
+func (app *application) exportDownloadURL(exportID int64, token string, expiry time.Time) string {
+	expires := expiry.Unix()
+
+	return fmt.Sprintf(
+		"%s/v1/users/exports/%d/download?token=%s&expires=%d&signature=%s",
+		app.config.apiURL, exportID, token, expires, app.signExport(exportID, expires),
+	)
+}
+
+// buildExportArchive writes each part of the user's data as a JSON file of
+// a ZIP archive.
+func buildExportArchive(data *store.UserData) ([]byte, error) {
+	files := []struct {
+		name    string
+		content any
+	}{
+		{"profile.json", data.Profile},
+		{"posts.json", data.Posts},
+		{"comments.json", data.Comments},
+		{"followers.json", data.Followers},
+		{"following.json", data.Following},
+		{"sessions.json", data.Sessions},
+	}
+
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+
+	for _, file := range files {
+		f, err := zw.Create(file.name)
+		if err != nil {
+			return nil, err
+		}
+
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(file.content); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// processExportsJob builds the pending data exports and emails their
+// download links. Failed exports are retried once their lease is over, and
+// given up after maxAttempts.
+func (app *application) processExportsJob(ctx context.Context) error {
+	cfg := app.config.exports
+
+	exports, err := app.store.Exports.ClaimPending(ctx, cfg.batchSize, cfg.lease)
+	if err != nil {
+		return err
+	}
+
+	for _, export := range exports {
+		if export.Attempts > cfg.maxAttempts {
+			app.logger.Errorw("data export failed", "export", export.ID, "user", export.UserID)
+			if err := app.store.Exports.Fail(ctx, export.ID); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := app.processExport(ctx, export); err != nil {
+			app.logger.Warnw("data export attempt failed", "export", export.ID, "attempt", export.Attempts, "error", err)
+		}
+	}
+
+	return nil
+}
+
+func (app *application) processExport(ctx context.Context, export store.DataExport) error {
+	data, err := app.store.Exports.CollectUserData(ctx, export.UserID)
+	if err != nil {
+		return err
+	}
+
+	archive, err := buildExportArchive(data)
+	if err != nil {
+		return err
+	}
+
+	expiry := time.Now().Add(app.config.exports.linkExp).Truncate(time.Second)
+	plainToken := uuid.New().String()
+
+	vars := struct {
+		Username    string
+		DownloadURL string
+		ExpiresIn   string
+	}{
+		Username:    data.Profile.Username,
+		DownloadURL: app.exportDownloadURL(export.ID, plainToken, expiry),
+		ExpiresIn:   mailer.FormatDuration(app.config.exports.linkExp, data.Profile.Language),
+	}
+
+	email, err := newOutboxEmail(mailer.DataExportTemplate, data.Profile, vars)
+	if err != nil {
+		return err
+	}
+
+	return app.store.Exports.Complete(ctx, export.ID, hashToken(plainToken), archive, expiry, email)
+}
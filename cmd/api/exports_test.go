@@ -0,0 +1,129 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/alejandro-cardenas-g/social/internal/mailer"
+	"github.com/alejandro-cardenas-g/social/internal/store"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDataExport(t *testing.T) {
+	cfg := config{
+		apiURL: "http://localhost:8080",
+		exports: exportsConfig{
+			signingSecret: "secret",
+			linkExp:       time.Hour,
+		},
+	}
+	app := newTestApplication(t, cfg)
+	mockAuthenticatedUser(app, &store.User{ID: mockUserID})
+	mux := app.mount()
+
+	exports := app.store.Exports.(*store.MockExportsStore)
+
+	download := func(t *testing.T, token string, expires int64, signature string) int {
+		path := fmt.Sprintf("/v1/users/exports/1/download?token=%s&expires=%d&signature=%s", token, expires, signature)
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should start an export", func(t *testing.T) {
+		exports.On("Create", mockUserID).Return(&store.DataExport{ID: 1, UserID: mockUserID, Status: store.ExportStatusPending}, nil).Once()
+
+		checkResponseCode(t, http.StatusAccepted, authenticatedRequest(t, app, mux, http.MethodPost, "/v1/users/me/exports", nil).Code)
+	})
+
+	t.Run("should not start a second export", func(t *testing.T) {
+		exports.On("Create", mockUserID).Return(nil, store.ErrConflict).Once()
+
+		checkResponseCode(t, http.StatusConflict, authenticatedRequest(t, app, mux, http.MethodPost, "/v1/users/me/exports", nil).Code)
+	})
+
+	t.Run("should download with a signed link and its token", func(t *testing.T) {
+		exports.On("GetArchive", int64(1), hashToken("token")).Return([]byte("archive"), nil).Once()
+
+		expires := time.Now().Add(time.Hour).Unix()
+		checkResponseCode(t, http.StatusOK, download(t, "token", expires, app.signExport(1, expires)))
+	})
+
+	t.Run("should not find an export with another token", func(t *testing.T) {
+		exports.On("GetArchive", int64(1), hashToken("guess")).Return(nil, store.ErrNotFound).Once()
+
+		expires := time.Now().Add(time.Hour).Unix()
+		checkResponseCode(t, http.StatusNotFound, download(t, "guess", expires, app.signExport(1, expires)))
+	})
+
+	t.Run("should reject tampered or expired links", func(t *testing.T) {
+		expires := time.Now().Add(time.Hour).Unix()
+		checkResponseCode(t, http.StatusForbidden, download(t, "token", expires+1, app.signExport(1, expires)))
+		checkResponseCode(t, http.StatusForbidden, download(t, "token", expires, "abc"))
+		checkResponseCode(t, http.StatusForbidden, download(t, "", expires, app.signExport(1, expires)))
+
+		expired := time.Now().Add(-time.Minute).Unix()
+		checkResponseCode(t, http.StatusForbidden, download(t, "token", expired, app.signExport(1, expired)))
+	})
+
+	t.Run("should email a link with the token of the export", func(t *testing.T) {
+		var link string
+		withLink := mock.MatchedBy(func(email *store.OutboxEmail) bool {
+			var vars struct{ DownloadURL string }
+			if err := json.Unmarshal(email.Data, &vars); err != nil {
+				return false
+			}
+			link = vars.DownloadURL
+			return email.Template == mailer.DataExportTemplate
+		})
+
+		var tokenHash string
+		hashed := mock.MatchedBy(func(token string) bool {
+			tokenHash = token
+			return token != ""
+		})
+
+		exports.On("Complete", int64(2), hashed, withLink).Return(nil).Once()
+
+		if err := app.processExport(context.Background(), store.DataExport{ID: 2, UserID: 1}); err != nil {
+			t.Fatal(err)
+		}
+
+		u, err := url.Parse(link)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if hashToken(u.Query().Get("token")) != tokenHash {
+			t.Errorf("expected the export to keep the hash of the token in %s", link)
+		}
+	})
+
+	t.Run("should archive every part of the data", func(t *testing.T) {
+		archive, err := buildExportArchive(&store.UserData{Profile: &store.User{ID: 1}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(zr.File) != 6 {
+			t.Errorf("expected 6 files in the archive, got %d", len(zr.File))
+		}
+	})
+
+	exports.AssertExpectations(t)
+}
@@ -9,26 +9,23 @@ import (
 // getUserFeedHandler godoc
 //
 //	@Summary		Fetches the user feed
-//	@Description	Fetches the user feed
+//	@Description	Fetches the posts of the authenticated user and of the users they follow, optionally within a since/until window
 //	@Tags			feed
 //	@Accept			json
 //	@Produce		json
-//	@Param			since	query		string	false	"Since"
-//	@Param			until	query		string	false	"Until"
+//	@Param			since	query		string	false	"Since, as 2006-01-02 15:04:05 in UTC or RFC 3339"
+//	@Param			until	query		string	false	"Until, as 2006-01-02 15:04:05 in UTC or RFC 3339"
 //	@Param			limit	query		int		false	"Limit"
 //	@Param			offset	query		int		false	"Offset"
 //	@Param			sort	query		string	false	"Sort"
 //	@Param			tags	query		string	false	"Tags"
-//	@Param			search	query		string	false	"Search"
+//	@Param			term	query		string	false	"Search term"
 //	@Success		200		{object}	[]store.PostWithMetadata
 //	@Failure		400		{object}	error
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/users/feed [get]
 func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Request) {
-
-	userID := int64(2)
-
 	fq := store.PaginatedFeedQuery{
 		Limit:  20,
 		Offset: 0,
@@ -48,7 +45,7 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	ctx := r.Context()
-	feed, err := app.store.Posts.GetUserFeed(ctx, userID, fq)
+	feed, err := app.store.Posts.GetUserFeed(ctx, getUserFromCtx(r).ID, fq)
 
 	if err != nil {
 		app.internalServerError(w, r, err)
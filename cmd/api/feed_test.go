@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/alejandro-cardenas-g/social/internal/store"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetUserFeed(t *testing.T) {
+	app := newTestApplication(t, config{})
+	// the feed is read for the user the token authenticates, not its subject
+	mockAuthenticatedUser(app, &store.User{ID: 7})
+	mux := app.mount()
+
+	mockStore := app.store.Posts.(*store.MockPostsStore)
+
+	get := func(t *testing.T, path string) int {
+		return authenticatedRequest(t, app, mux, http.MethodGet, path, nil).Code
+	}
+
+	t.Run("should fetch the feed of the authenticated user", func(t *testing.T) {
+		mockStore.On("GetUserFeed", int64(7), mock.Anything).Return(nil, nil).Once()
+
+		checkResponseCode(t, http.StatusOK, get(t, "/v1/users/feed"))
+
+		mockStore.AssertExpectations(t)
+	})
+
+	t.Run("should pass the time window", func(t *testing.T) {
+		fq := store.PaginatedFeedQuery{
+			Limit: 10,
+			Sort:  "asc",
+			Since: "2024-01-01T00:00:00Z",
+			Until: "2024-02-01T12:30:00Z",
+		}
+		mockStore.On("GetUserFeed", int64(7), fq).Return(nil, nil).Once()
+
+		checkResponseCode(t, http.StatusOK, get(t, "/v1/users/feed?limit=10&sort=asc&since=2024-01-01%2000:00:00&until=2024-02-01T12:30:00Z"))
+
+		mockStore.AssertExpectations(t)
+	})
+
+	t.Run("should reject malformed query params", func(t *testing.T) {
+		for _, qs := range []string{
+			"limit=abc",
+			"limit=50",
+			"offset=-1",
+			"offset=abc",
+			"sort=sideways",
+			"since=yesterday",
+			"until=2024-13-01%2000:00:00",
+			"since=2024-02-01%2000:00:00&until=2024-01-01%2000:00:00",
+		} {
+			if code := get(t, "/v1/users/feed?"+qs); code != http.StatusBadRequest {
+				t.Errorf("expected %s to be rejected with %d, got %d", qs, http.StatusBadRequest, code)
+			}
+		}
+
+		mockStore.AssertNumberOfCalls(t, "GetUserFeed", 2)
+	})
+}
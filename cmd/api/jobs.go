@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/alejandro-cardenas-g/social/internal/store"
+)
+
+// startJobs runs the background jobs of the API until ctx is cancelled.
+func (app *application) startJobs(ctx context.Context) {
+	go app.runPeriodically(ctx, "cleanup", app.config.jobs.cleanupInterval, app.cleanupJob)
+	go app.runPeriodically(ctx, "mail dispatcher", app.config.mail.outbox.pollInterval, app.dispatchEmailsJob)
+	go app.runPeriodically(ctx, "mail retention", app.config.jobs.cleanupInterval, app.purgeEmailsJob)
+	go app.runPeriodically(ctx, "account deletion", app.config.jobs.cleanupInterval, app.deleteAccountsJob)
+	go app.runPeriodically(ctx, "data exports", app.config.exports.pollInterval, app.processExportsJob)
+}
+
+// background runs fn outside of the request that started it, with a context
+// that is not cancelled when the response is written. Shutdown waits for it.
+func (app *application) background(name string, fn func(ctx context.Context) error) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.Errorw("background task panicked", "task", name, "error", err)
+			}
+		}()
+
+		if err := fn(context.Background()); err != nil {
+			app.logger.Errorw("background task failed", "task", name, "error", err)
+		}
+	}()
+}
+
+func (app *application) runPeriodically(ctx context.Context, name string, interval time.Duration, job func(ctx context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := job(ctx); err != nil && ctx.Err() == nil {
+			app.logger.Errorw("job failed", "job", name, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// cleanupJob purges expired invitations, magic links, email changes, data
+// exports, sessions and login attempts, and accounts that were never
+// activated within the grace period.
+func (app *application) cleanupJob(ctx context.Context) error {
+	invitations, err := app.store.Users.DeleteExpiredInvitations(ctx)
+	if err != nil {
+		return err
+	}
+
+	magicLinks, err := app.store.MagicLinks.DeleteExpired(ctx)
+	if err != nil {
+		return err
+	}
+
+	emailChanges, err := app.store.Users.DeleteExpiredEmailChanges(ctx)
+	if err != nil {
+		return err
+	}
+
+	exports, err := app.store.Exports.DeleteExpired(ctx)
+	if err != nil {
+		return err
+	}
+
+	// revoked sessions are kept while their access tokens are valid
+	sessions, err := app.store.Sessions.DeleteExpired(ctx, time.Now().Add(-app.config.auth.token.exp))
+	if err != nil {
+		return err
+	}
+
+	loginAttempts, err := app.store.LoginAttempts.DeleteExpired(ctx, app.config.auth.lockout.window)
+	if err != nil {
+		return err
+	}
+
+	users, err := app.store.Users.DeleteUnactivated(ctx, app.config.jobs.unactivatedGracePeriod)
+	if err != nil {
+		return err
+	}
+
+	if invitations > 0 || magicLinks > 0 || emailChanges > 0 || exports > 0 || sessions > 0 || loginAttempts > 0 || users > 0 {
+		app.logger.Infow("cleanup done", "invitations", invitations, "magic_links", magicLinks, "email_changes", emailChanges, "data_exports", exports, "sessions", sessions, "login_attempts", loginAttempts, "users", users)
+	}
+
+	return nil
+}
+
+// deleteAccountsBatchSize is how many accounts deleteAccountsJob deletes per
+// run.
+const deleteAccountsBatchSize = 100
+
+// deleteAccountsJob deletes the accounts whose deletion grace period is
+// over, each one in its own transaction so a failure does not hold back the
+// others.
+func (app *application) deleteAccountsJob(ctx context.Context) error {
+	ids, err := app.store.Users.GetDueDeletions(ctx, deleteAccountsBatchSize)
+	if err != nil {
+		return err
+	}
+
+	var deleted int
+	var errs []error
+
+	for _, id := range ids {
+		if err := app.store.Users.DeleteScheduled(ctx, id); err != nil {
+			// cancelled since it was listed
+			if errors.Is(err, store.ErrNotFound) {
+				continue
+			}
+			errs = append(errs, err)
+			continue
+		}
+
+		deleted++
+
+		if err := app.invalidateUser(ctx, id); err != nil {
+			errs = append(errs, err)
+		}
+
+		if err := app.invalidateSuggestions(ctx, id); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if deleted > 0 {
+		app.logger.Infow("accounts deleted", "users", deleted)
+	}
+
+	return errors.Join(errs...)
+}
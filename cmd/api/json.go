@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/alejandro-cardenas-g/social/internal/mailer"
 	"github.com/go-playground/validator/v10"
 )
 
@@ -11,6 +12,14 @@ var Validate *validator.Validate
 
 func init() {
 	Validate = validator.New(validator.WithRequiredStructEnabled())
+
+	// language accepts the languages the email templates are translated to
+	err := Validate.RegisterValidation("language", func(fl validator.FieldLevel) bool {
+		return mailer.IsSupportedLanguage(fl.Field().String())
+	})
+	if err != nil {
+		panic(err)
+	}
 }
 
 func writeJSON(w http.ResponseWriter, status int, data any) error {
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alejandro-cardenas-g/social/internal/mailer"
+	"github.com/alejandro-cardenas-g/social/internal/store"
+)
+
+type loginAttemptsStore interface {
+	LockedFor(ctx context.Context, key string) (time.Duration, error)
+	RegisterFailure(ctx context.Context, key string, maxAttempts int, window, lockout time.Duration) (bool, error)
+	Reset(ctx context.Context, key string) error
+}
+
+func (app *application) loginAttempts() loginAttemptsStore {
+	if app.config.redisCfg.enabled {
+		return app.cacheStorage.LoginAttempts
+	}
+	return app.store.LoginAttempts
+}
+
+// loginLockedFor returns how long logins from the client IP, or for any of
+// the account keys, remain locked.
+func (app *application) loginLockedFor(ctx context.Context, r *http.Request, keys ...string) (time.Duration, error) {
+	lockedFor, err := app.loginAttempts().LockedFor(ctx, ipAttemptsKey(r))
+	if err != nil {
+		return 0, err
+	}
+
+	for _, key := range keys {
+		keyLockedFor, err := app.loginAttempts().LockedFor(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+
+		lockedFor = max(lockedFor, keyLockedFor)
+	}
+
+	return lockedFor, nil
+}
+
+// failedLogin counts the failure against the account key and the client IP
+// and answers the request. user is nil when the email is unknown.
+func (app *application) failedLogin(w http.ResponseWriter, r *http.Request, key string, user *store.User, err error) {
+	ctx := r.Context()
+	cfg := app.config.auth.lockout
+
+	if _, err := app.loginAttempts().RegisterFailure(ctx, ipAttemptsKey(r), cfg.maxIPAttempts, cfg.window, cfg.lockout); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	locked, lockErr := app.loginAttempts().RegisterFailure(ctx, key, cfg.maxAttempts, cfg.window, cfg.lockout)
+	if lockErr != nil {
+		app.internalServerError(w, r, lockErr)
+		return
+	}
+
+	if locked && user != nil {
+		app.logger.Warnw("account locked", "user_id", user.ID)
+		app.sendAccountLockedEmail(r.Context(), user)
+	}
+
+	app.unauthorizedError(w, r, err)
+}
+
+// checkCurrentPassword confirms that the authenticated user knows password
+// before a sensitive change. Wrong passwords count toward the same lockout
+// as logins. It answers the request and returns nil when the check fails.
+func (app *application) checkCurrentPassword(w http.ResponseWriter, r *http.Request, password string) *store.User {
+	ctx := r.Context()
+
+	// the user in the context may come from the cache, which has no password
+	user, err := app.store.Users.GetByEmail(ctx, getUserFromCtx(r).Email)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unauthorizedError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return nil
+	}
+
+	key := accountAttemptsKey(user.Email)
+
+	lockedFor, err := app.loginLockedFor(ctx, r, key)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return nil
+	}
+
+	if lockedFor > 0 {
+		app.loginLockedError(w, r, strconv.Itoa(int(lockedFor.Seconds())))
+		return nil
+	}
+
+	if err := user.Password.Compare(password); err != nil {
+		app.failedLogin(w, r, key, user, errors.New("current password is incorrect"))
+		return nil
+	}
+
+	return user
+}
+
+// resetLoginFailures clears the password and second factor failures of the
+// user. It is only called once a login is complete.
+func (app *application) resetLoginFailures(ctx context.Context, user *store.User) error {
+	if err := app.loginAttempts().Reset(ctx, accountAttemptsKey(user.Email)); err != nil {
+		return err
+	}
+
+	return app.loginAttempts().Reset(ctx, twoFactorAttemptsKey(user.ID))
+}
+
+func (app *application) sendAccountLockedEmail(ctx context.Context, user *store.User) {
+	vars := struct {
+		Username  string
+		LockedFor string
+		ResetURL  string
+	}{
+		Username:  user.Username,
+		LockedFor: mailer.FormatDuration(app.config.auth.lockout.lockout, user.Language),
+		ResetURL:  fmt.Sprintf("%s/forgot-password", app.config.frontendURL),
+	}
+
+	if err := app.enqueueEmail(ctx, mailer.AccountLockedTemplate, user, vars); err != nil {
+		app.logger.Errorw("Error queueing account locked email", "error", err)
+	}
+}
+
+func accountAttemptsKey(email string) string {
+	return "email:" + strings.ToLower(email)
+}
+
+// twoFactorAttemptsKey counts wrong codes separately from wrong passwords,
+// so a correct password does not clear them.
+func twoFactorAttemptsKey(userID int64) string {
+	return fmt.Sprintf("2fa:%d", userID)
+}
+
+func ipAttemptsKey(r *http.Request) string {
+	return "ip:" + clientIP(r)
+}
+
+// clientIP returns the IP of the client as resolved by middleware.RealIP.
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		// middleware.RealIP sets RemoteAddr without a port
+		return r.RemoteAddr
+	}
+	return ip
+}
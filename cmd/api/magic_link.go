@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/alejandro-cardenas-g/social/internal/mailer"
+	"github.com/alejandro-cardenas-g/social/internal/store"
+	"github.com/google/uuid"
+)
+
+type MagicLinkPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+type MagicLinkTokenPayload struct {
+	Token string `json:"token" validate:"required,max=255"`
+}
+
+// requestMagicLinkHandler godoc
+//
+//	@Summary		Requests a magic login link
+//	@Description	Emails a single-use login link if the email belongs to an active user
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		MagicLinkPayload	true	"User email"
+//	@Success		202		{string}	string				"Link requested"
+//	@Failure		400		{object}	error
+//	@Failure		429		{object}	error
+//	@Router			/auth/magic-link [post]
+func (app *application) requestMagicLinkHandler(w http.ResponseWriter, r *http.Request) {
+	var payload MagicLinkPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if allow, retryAfter := app.emailRateLimiter.Allow("magic-link:" + strings.ToLower(payload.Email)); !allow {
+		app.rateLimitExceededError(w, r, retryAfter.String())
+		return
+	}
+
+	// the lookup runs after responding, so neither the timing nor the status
+	// discloses which emails are registered
+	app.background("magic link", func(ctx context.Context) error {
+		return app.requestMagicLink(ctx, payload.Email)
+	})
+
+	if err := app.jsonResponse(w, http.StatusAccepted, nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// requestMagicLink stores a login link for the active user of email, if any,
+// and queues the email with the link in the same transaction.
+func (app *application) requestMagicLink(ctx context.Context, email string) error {
+	user, err := app.store.Users.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	plainToken := uuid.New().String()
+
+	vars := struct {
+		Username  string
+		LoginURL  string
+		ExpiresIn string
+	}{
+		Username:  user.Username,
+		LoginURL:  fmt.Sprintf("%s/magic-link/%s", app.config.frontendURL, plainToken),
+		ExpiresIn: mailer.FormatDuration(app.config.mail.magicLinkExp, user.Language),
+	}
+
+	linkEmail, err := newOutboxEmail(mailer.MagicLinkTemplate, user, vars)
+	if err != nil {
+		return err
+	}
+
+	return app.store.MagicLinks.Create(ctx, user.ID, hashToken(plainToken), app.config.mail.magicLinkExp, linkEmail)
+}
+
+// magicLinkTokenHandler godoc
+//
+//	@Summary		Logs in with a magic link
+//	@Description	Exchanges a magic link token for the same tokens as a password login. The link can only be used once
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		MagicLinkTokenPayload	true	"Magic link token"
+//	@Success		201		{object}	TokenResponse
+//	@Success		202		{object}	TwoFactorChallengeResponse
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/auth/magic-link/token [post]
+func (app *application) magicLinkTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var payload MagicLinkTokenPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	user, err := app.store.MagicLinks.Consume(r.Context(), hashToken(payload.Token))
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unauthorizedError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.completeLogin(w, r, user)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alejandro-cardenas-g/social/internal/mailer"
+	ratelimiter "github.com/alejandro-cardenas-g/social/internal/rateLimiter"
+	"github.com/alejandro-cardenas-g/social/internal/store"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestMagicLink(t *testing.T) {
+	cfg := config{
+		mail: mailConfig{magicLinkExp: time.Minute * 15},
+	}
+	app := newTestApplication(t, cfg)
+	app.emailRateLimiter = ratelimiter.NewFixedWindowRateLimiter(2, time.Minute)
+	mux := app.mount()
+
+	users := app.store.Users.(*store.MockUserStore)
+	magicLinks := app.store.MagicLinks.(*store.MockMagicLinksStore)
+
+	post := func(t *testing.T, path, body string) int {
+		req, err := http.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		code := executeRequest(req, mux).Code
+		app.wg.Wait()
+		return code
+	}
+
+	t.Run("should respond the same to an unknown email", func(t *testing.T) {
+		users.On("GetByEmail", "nobody@example.com").Return(nil, store.ErrNotFound).Once()
+
+		checkResponseCode(t, http.StatusAccepted, post(t, "/v1/auth/magic-link", `{"email":"nobody@example.com"}`))
+	})
+
+	t.Run("should respond the same when the link cannot be stored", func(t *testing.T) {
+		users.On("GetByEmail", "broken@example.com").Return(&store.User{ID: 2, Email: "broken@example.com"}, nil).Once()
+		magicLinks.On("Create", int64(2), cfg.mail.magicLinkExp, mock.Anything).Return(errors.New("connection refused")).Once()
+
+		checkResponseCode(t, http.StatusAccepted, post(t, "/v1/auth/magic-link", `{"email":"broken@example.com"}`))
+	})
+
+	t.Run("should rate limit the links requested for an email", func(t *testing.T) {
+		linkEmail := mock.MatchedBy(func(email *store.OutboxEmail) bool {
+			return email.Template == mailer.MagicLinkTemplate && email.Email == "ana@example.com"
+		})
+
+		users.On("GetByEmail", "ana@example.com").Return(&store.User{ID: 1, Email: "ana@example.com"}, nil).Twice()
+		magicLinks.On("Create", int64(1), cfg.mail.magicLinkExp, linkEmail).Return(nil).Twice()
+
+		checkResponseCode(t, http.StatusAccepted, post(t, "/v1/auth/magic-link", `{"email":"ana@example.com"}`))
+		checkResponseCode(t, http.StatusAccepted, post(t, "/v1/auth/magic-link", `{"email":"ana@example.com"}`))
+		checkResponseCode(t, http.StatusTooManyRequests, post(t, "/v1/auth/magic-link", `{"email":"Ana@example.com"}`))
+	})
+
+	t.Run("should log in with a link only once", func(t *testing.T) {
+		magicLinks.On("Consume", hashToken("link")).Return(&store.User{ID: 1}, nil).Once()
+		magicLinks.On("Consume", hashToken("link")).Return(nil, store.ErrNotFound).Once()
+
+		checkResponseCode(t, http.StatusCreated, post(t, "/v1/auth/magic-link/token", `{"token":"link"}`))
+		checkResponseCode(t, http.StatusUnauthorized, post(t, "/v1/auth/magic-link/token", `{"token":"link"}`))
+	})
+
+	t.Run("should reject an expired link", func(t *testing.T) {
+		magicLinks.On("Consume", hashToken("expired")).Return(nil, store.ErrNotFound).Once()
+
+		checkResponseCode(t, http.StatusUnauthorized, post(t, "/v1/auth/magic-link/token", `{"token":"expired"}`))
+	})
+
+	users.AssertExpectations(t)
+	magicLinks.AssertExpectations(t)
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -55,7 +56,32 @@ func (app *application) BasicAuthMiddleware() func(http.Handler) http.Handler {
 	}
 }
 
+type authOptions struct {
+	// enforceTwoFactor rejects users whose role requires 2FA but who have
+	// not enrolled yet
+	enforceTwoFactor bool
+	// allowAccessTokens accepts personal access tokens besides JWTs
+	allowAccessTokens bool
+}
+
 func (app *application) AuthTokenMiddleware() func(http.Handler) http.Handler {
+	return app.authTokenMiddleware(authOptions{enforceTwoFactor: true, allowAccessTokens: true})
+}
+
+// SessionAuthMiddleware only accepts JWTs from an interactive login, for
+// endpoints personal access tokens must not reach, like managing them.
+func (app *application) SessionAuthMiddleware() func(http.Handler) http.Handler {
+	return app.authTokenMiddleware(authOptions{enforceTwoFactor: true})
+}
+
+// TwoFactorSetupMiddleware works like SessionAuthMiddleware but lets through
+// users whose role requires 2FA and who have not enrolled yet, so they can
+// do it.
+func (app *application) TwoFactorSetupMiddleware() func(http.Handler) http.Handler {
+	return app.authTokenMiddleware(authOptions{})
+}
+
+func (app *application) authTokenMiddleware(opts authOptions) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			authHeader := r.Header.Get("Authorization")
@@ -71,28 +97,71 @@ func (app *application) AuthTokenMiddleware() func(http.Handler) http.Handler {
 			}
 
 			token := parts[1]
+			ctx := r.Context()
 
-			jwtToken, err := app.authenticator.ValidateToken(token)
-			if err != nil {
-				app.unauthorizedError(w, r, err)
-				return
+			var userID int64
+
+			if strings.HasPrefix(token, accessTokenPrefix) {
+				if !opts.allowAccessTokens {
+					app.unauthorizedError(w, r, fmt.Errorf("personal access tokens are not accepted"))
+					return
+				}
+
+				accessToken, err := app.store.AccessTokens.Authenticate(ctx, hashToken(token))
+				if err != nil {
+					app.unauthorizedError(w, r, err)
+					return
+				}
+
+				userID = accessToken.UserID
+				ctx = context.WithValue(ctx, scopesCtx, accessToken.Scopes)
+			} else {
+				jwtToken, err := app.authenticator.ValidateToken(token)
+				if err != nil {
+					app.unauthorizedError(w, r, err)
+					return
+				}
+
+				claims := jwtToken.Claims.(jwt.MapClaims)
+
+				if typ, _ := claims["typ"].(string); typ != accessTokenType {
+					app.unauthorizedError(w, r, fmt.Errorf("token is not an access token"))
+					return
+				}
+
+				userID, err = strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
+
+				if err != nil {
+					app.unauthorizedError(w, r, err)
+					return
+				}
+
+				revoked, err := app.isTokenRevoked(ctx, claims, userID)
+				if err != nil {
+					app.internalServerError(w, r, err)
+					return
+				}
+
+				if revoked {
+					app.unauthorizedError(w, r, fmt.Errorf("token has been revoked"))
+					return
+				}
+
+				sid, _ := claims["sid"].(string)
+				app.touchSession(ctx, sid)
+
+				ctx = context.WithValue(ctx, claimsCtx, claims)
 			}
 
-			claims := jwtToken.Claims.(jwt.MapClaims)
-
-			userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
+			user, err := app.getUser(ctx, userID)
 
 			if err != nil {
 				app.unauthorizedError(w, r, err)
 				return
 			}
 
-			ctx := r.Context()
-
-			user, err := app.getUser(ctx, userID)
-
-			if err != nil {
-				app.unauthorizedError(w, r, err)
+			if opts.enforceTwoFactor && app.twoFactorRequired(user) && !user.TwoFactorEnabled {
+				app.twoFactorRequiredError(w, r)
 				return
 			}
 
@@ -103,6 +172,22 @@ func (app *application) AuthTokenMiddleware() func(http.Handler) http.Handler {
 	}
 }
 
+// CheckScopeMiddleware restricts requests authenticated with a personal
+// access token to the scopes granted to it. Logged in users have every scope.
+func (app *application) CheckScopeMiddleware(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scopes, ok := r.Context().Value(scopesCtx).([]string)
+			if ok && !slices.Contains(scopes, scope) {
+				app.forbiddenError(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func (app *application) CheckPostOwnershipMiddleware(requiredRole string, next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
@@ -129,6 +214,25 @@ func (app *application) CheckPostOwnershipMiddleware(requiredRole string, next h
 	})
 }
 
+func (app *application) CheckRoleMiddleware(requiredRole string, next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := getUserFromCtx(r)
+
+		allowed, err := app.checkRolePrecedence(r.Context(), user, requiredRole)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if !allowed {
+			app.forbiddenError(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (app *application) checkRolePrecedence(ctx context.Context, user *store.User, roleName string) (bool, error) {
 	role, err := app.store.Roles.GetByName(ctx, roleName)
 	if err != nil {
@@ -162,6 +266,14 @@ func (app *application) getUser(ctx context.Context, userID int64) (*store.User,
 	return cacheUser, nil
 }
 
+func (app *application) invalidateUser(ctx context.Context, userID int64) error {
+	if !app.config.redisCfg.enabled {
+		return nil
+	}
+
+	return app.cacheStorage.Users.Delete(ctx, userID)
+}
+
 func (app *application) RateLimiterMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if app.config.rateLimiter.Enabled {
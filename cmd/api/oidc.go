@@ -0,0 +1,364 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alejandro-cardenas-g/social/internal/auth"
+	"github.com/alejandro-cardenas-g/social/internal/store"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	oidcStateTokenType = "oidc_state"
+	oidcLoginTokenType = "oidc_login"
+	oidcStateCookie    = "oidc_state"
+	oidcStateExp       = time.Minute * 10
+	oidcLoginExp       = time.Minute
+)
+
+type oidcProvider interface {
+	Issuer() string
+	AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error)
+	Exchange(ctx context.Context, code, codeVerifier string) (string, error)
+	VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*auth.OIDCClaims, error)
+}
+
+type OIDCTokenPayload struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// oidcLoginHandler godoc
+//
+//	@Summary		Starts an OpenID Connect login
+//	@Description	Redirects the browser to the identity provider. The state, nonce and PKCE verifier are kept in a signed cookie
+//	@Tags			auth
+//	@Success		302	{string}	string	"Redirect to the identity provider"
+//	@Failure		500	{object}	error
+//	@Router			/auth/oidc/login [get]
+func (app *application) oidcLoginHandler(w http.ResponseWriter, r *http.Request) {
+	state, err := auth.GenerateRandomString(32)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	nonce, err := auth.GenerateRandomString(32)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	verifier, challenge, err := auth.GeneratePKCE()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	authURL, err := app.oidc.AuthCodeURL(r.Context(), state, nonce, challenge)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	cookie, err := app.generateOIDCStateToken(state, nonce, verifier)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.setOIDCStateCookie(w, cookie, int(oidcStateExp.Seconds()))
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// oidcCallbackHandler godoc
+//
+//	@Summary		Completes an OpenID Connect login
+//	@Description	Verifies the provider response, links the identity to a user, creating and activating it on first login, and redirects to the frontend with a one-time login code
+//	@Tags			auth
+//	@Param			code	query		string	true	"Authorization code"
+//	@Param			state	query		string	true	"State"
+//	@Success		302		{string}	string	"Redirect to the frontend"
+//	@Router			/auth/oidc/callback [get]
+func (app *application) oidcCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+
+	cookie, err := r.Cookie(oidcStateCookie)
+	app.setOIDCStateCookie(w, "", -1)
+
+	if err != nil {
+		app.oidcRedirect(w, r, url.Values{"error": {"invalid_state"}})
+		return
+	}
+
+	if providerErr := query.Get("error"); providerErr != "" {
+		app.logger.Warnw("oidc provider error", "error", providerErr, "description", query.Get("error_description"))
+		app.oidcRedirect(w, r, url.Values{"error": {"access_denied"}})
+		return
+	}
+
+	claims, err := app.validateTypedToken(cookie.Value, oidcStateTokenType)
+	if err != nil {
+		app.oidcRedirect(w, r, url.Values{"error": {"invalid_state"}})
+		return
+	}
+
+	state, _ := claims["state"].(string)
+	nonce, _ := claims["nonce"].(string)
+	verifier, _ := claims["cv"].(string)
+
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(query.Get("state"))) != 1 {
+		app.oidcRedirect(w, r, url.Values{"error": {"invalid_state"}})
+		return
+	}
+
+	rawIDToken, err := app.oidc.Exchange(ctx, query.Get("code"), verifier)
+	if err != nil {
+		app.logger.Errorw("oidc code exchange failed", "error", err)
+		app.oidcRedirect(w, r, url.Values{"error": {"login_failed"}})
+		return
+	}
+
+	idClaims, err := app.oidc.VerifyIDToken(ctx, rawIDToken, nonce)
+	if err != nil {
+		app.logger.Warnw("oidc id token rejected", "error", err)
+		app.oidcRedirect(w, r, url.Values{"error": {"login_failed"}})
+		return
+	}
+
+	userID, err := app.oidcUser(ctx, idClaims)
+	if err != nil {
+		switch err {
+		case errOIDCEmailNotVerified:
+			app.oidcRedirect(w, r, url.Values{"error": {"email_not_verified"}})
+		default:
+			app.logger.Errorw("oidc login failed", "error", err)
+			app.oidcRedirect(w, r, url.Values{"error": {"login_failed"}})
+		}
+		return
+	}
+
+	code, err := app.generateOIDCLoginToken(userID)
+	if err != nil {
+		app.logger.Errorw("oidc login failed", "error", err)
+		app.oidcRedirect(w, r, url.Values{"error": {"login_failed"}})
+		return
+	}
+
+	app.oidcRedirect(w, r, url.Values{"code": {code}})
+}
+
+// oidcTokenHandler godoc
+//
+//	@Summary		Exchanges an OpenID Connect login code
+//	@Description	Exchanges the one-time code the callback redirected with for the same tokens as a password login
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		OIDCTokenPayload	true	"Login code"
+//	@Success		201		{object}	TokenResponse
+//	@Success		202		{object}	TwoFactorChallengeResponse
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/auth/oidc/token [post]
+func (app *application) oidcTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var payload OIDCTokenPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	claims, err := app.validateTypedToken(payload.Code, oidcLoginTokenType)
+	if err != nil {
+		app.unauthorizedError(w, r, err)
+		return
+	}
+
+	userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
+	if err != nil {
+		app.unauthorizedError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	revoked, err := app.isTokenRevoked(ctx, claims, userID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if revoked {
+		app.unauthorizedError(w, r, errors.New("login code already used"))
+		return
+	}
+
+	exp, err := claims.GetExpirationTime()
+	if err != nil {
+		app.unauthorizedError(w, r, err)
+		return
+	}
+
+	// login codes are single use
+	if err := app.tokenRevocations().Revoke(ctx, claims["jti"].(string), exp.Time); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	user, err := app.store.Users.GetByID(ctx, userID)
+	if err != nil {
+		app.unauthorizedError(w, r, err)
+		return
+	}
+
+	app.completeLogin(w, r, user)
+}
+
+var errOIDCEmailNotVerified = errors.New("the identity provider did not verify the email")
+
+// oidcUser returns the user linked to the identity, linking it on first
+// login to the account with the same email or to a new one.
+func (app *application) oidcUser(ctx context.Context, claims *auth.OIDCClaims) (int64, error) {
+	provider := app.oidc.Issuer()
+
+	userID, err := app.store.Identities.GetUserID(ctx, provider, claims.Subject)
+	if err == nil {
+		return userID, nil
+	}
+
+	if err != store.ErrNotFound {
+		return 0, err
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return 0, errOIDCEmailNotVerified
+	}
+
+	user := &store.User{
+		Username: oidcUsername(claims),
+		Email:    claims.Email,
+		Role: store.Role{
+			Name: "user",
+		},
+	}
+
+	// the account can only be used through the provider until the user
+	// resets the password
+	password, err := auth.GenerateRandomString(32)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := user.Password.Set(password, app.config.auth.hashing); err != nil {
+		return 0, err
+	}
+
+	identity := &store.Identity{
+		Provider: provider,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}
+
+	if err := app.store.Identities.LinkOrCreate(ctx, identity, user); err != nil {
+		return 0, err
+	}
+
+	if err := app.invalidateUser(ctx, user.ID); err != nil {
+		return 0, err
+	}
+
+	return user.ID, nil
+}
+
+func oidcUsername(claims *auth.OIDCClaims) string {
+	username := strings.TrimSpace(claims.PreferredUsername)
+	if username == "" {
+		username, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	if len(username) > 90 {
+		username = username[:90]
+	}
+
+	return username
+}
+
+func (app *application) oidcRedirect(w http.ResponseWriter, r *http.Request, params url.Values) {
+	http.Redirect(w, r, fmt.Sprintf("%s/oidc/callback?%s", app.config.frontendURL, params.Encode()), http.StatusFound)
+}
+
+func (app *application) setOIDCStateCookie(w http.ResponseWriter, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    value,
+		Path:     "/v1/auth/oidc",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   app.config.env == "production",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (app *application) generateOIDCStateToken(state, nonce, verifier string) (string, error) {
+	claims := jwt.MapClaims{
+		"typ":   oidcStateTokenType,
+		"state": state,
+		"nonce": nonce,
+		"cv":    verifier,
+		"exp":   time.Now().Add(oidcStateExp).Unix(),
+		"iat":   time.Now().Unix(),
+		"nbf":   time.Now().Unix(),
+		"iss":   app.config.auth.token.iss,
+		"aud":   app.config.auth.token.iss,
+	}
+
+	return app.authenticator.GenerateToken(claims)
+}
+
+func (app *application) generateOIDCLoginToken(userID int64) (string, error) {
+	claims := jwt.MapClaims{
+		"jti": uuid.New().String(),
+		"typ": oidcLoginTokenType,
+		"sub": userID,
+		"exp": time.Now().Add(oidcLoginExp).Unix(),
+		"iat": time.Now().Unix(),
+		"nbf": time.Now().Unix(),
+		"iss": app.config.auth.token.iss,
+		"aud": app.config.auth.token.iss,
+	}
+
+	return app.authenticator.GenerateToken(claims)
+}
+
+// validateTypedToken validates a token issued by the API and checks it is
+// of the given type.
+func (app *application) validateTypedToken(token, typ string) (jwt.MapClaims, error) {
+	jwtToken, err := app.authenticator.ValidateToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwtToken.Claims.(jwt.MapClaims)
+
+	if t, _ := claims["typ"].(string); t != typ {
+		return nil, fmt.Errorf("token is not of type %s", typ)
+	}
+
+	return claims, nil
+}
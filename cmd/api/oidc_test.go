@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/alejandro-cardenas-g/social/internal/auth"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// fakeOIDCProvider accepts any code and returns claims bound to the nonce
+// of the last authorization request.
+type fakeOIDCProvider struct {
+	nonce  string
+	claims auth.OIDCClaims
+}
+
+func (p *fakeOIDCProvider) Issuer() string { return "https://idp.example.com" }
+
+func (p *fakeOIDCProvider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	p.nonce = nonce
+	return "https://idp.example.com/authorize?" + url.Values{"state": {state}}.Encode(), nil
+}
+
+func (p *fakeOIDCProvider) Exchange(ctx context.Context, code, codeVerifier string) (string, error) {
+	return "id-token", nil
+}
+
+func (p *fakeOIDCProvider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*auth.OIDCClaims, error) {
+	if nonce != p.nonce {
+		return nil, auth.ErrInvalidIDToken
+	}
+	return &p.claims, nil
+}
+
+func TestOIDCLogin(t *testing.T) {
+	cfg := config{
+		frontendURL: "http://frontend",
+		auth:        authConfig{token: tokenConfig{iss: "test"}},
+	}
+
+	app := newTestApplication(t, cfg)
+	app.authenticator = auth.NewJWtAuthenticator("secret", "test", "test")
+
+	provider := &fakeOIDCProvider{claims: auth.OIDCClaims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "external-42"},
+		Email:            "gopher@example.com",
+		EmailVerified:    true,
+	}}
+	app.oidc = provider
+
+	mux := app.mount()
+
+	login := func(t *testing.T) (*http.Cookie, string) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/auth/oidc/login", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusFound, rr.Code)
+
+		location, err := url.Parse(rr.Header().Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cookies := rr.Result().Cookies()
+		if len(cookies) != 1 || cookies[0].Name != oidcStateCookie || !cookies[0].HttpOnly {
+			t.Fatalf("expected an HttpOnly state cookie, got %v", cookies)
+		}
+
+		return cookies[0], location.Query().Get("state")
+	}
+
+	callback := func(t *testing.T, cookie *http.Cookie, state string) url.Values {
+		req, err := http.NewRequest(http.MethodGet, "/v1/auth/oidc/callback?"+url.Values{"code": {"code"}, "state": {state}}.Encode(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusFound, rr.Code)
+
+		location, err := url.Parse(rr.Header().Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := location.Scheme + "://" + location.Host + location.Path; got != "http://frontend/oidc/callback" {
+			t.Fatalf("unexpected redirect %s", location)
+		}
+
+		return location.Query()
+	}
+
+	t.Run("should redirect back with a login code", func(t *testing.T) {
+		cookie, state := login(t)
+
+		query := callback(t, cookie, state)
+		if query.Get("code") == "" {
+			t.Fatalf("expected a login code, got %v", query)
+		}
+
+		claims, err := app.validateTypedToken(query.Get("code"), oidcLoginTokenType)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if sub, _ := claims["sub"].(float64); sub != 1 {
+			t.Errorf("expected the code to be issued to the linked user, got %v", claims["sub"])
+		}
+	})
+
+	t.Run("should reject a state that does not match the cookie", func(t *testing.T) {
+		cookie, _ := login(t)
+
+		if got := callback(t, cookie, "forged").Get("error"); got != "invalid_state" {
+			t.Errorf("expected invalid_state, got %q", got)
+		}
+	})
+
+	t.Run("should reject a callback without the state cookie", func(t *testing.T) {
+		_, state := login(t)
+
+		if got := callback(t, nil, state).Get("error"); got != "invalid_state" {
+			t.Errorf("expected invalid_state, got %q", got)
+		}
+	})
+
+	t.Run("should reject unverified emails of unknown identities", func(t *testing.T) {
+		provider.claims.EmailVerified = false
+		defer func() { provider.claims.EmailVerified = true }()
+
+		cookie, state := login(t)
+
+		if got := callback(t, cookie, state).Get("error"); got != "email_not_verified" {
+			t.Errorf("expected email_not_verified, got %q", got)
+		}
+	})
+}
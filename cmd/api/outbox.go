@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/alejandro-cardenas-g/social/internal/mailer"
+	"github.com/alejandro-cardenas-g/social/internal/store"
+)
+
+// newOutboxEmail builds an outbox entry for templateFile addressed to user,
+// in the user's language when the template is translated. data is stored as
+// JSON, so templates see it as a map keyed by field name.
+func newOutboxEmail(templateFile string, user *store.User, data any) (*store.OutboxEmail, error) {
+	vars, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &store.OutboxEmail{
+		Template: mailer.LocalizedTemplate(templateFile, user.Language),
+		Username: user.Username,
+		Email:    user.Email,
+		Data:     vars,
+	}, nil
+}
+
+// enqueueEmail queues an email to be delivered by the dispatcher.
+func (app *application) enqueueEmail(ctx context.Context, templateFile string, user *store.User, data any) error {
+	email, err := newOutboxEmail(templateFile, user, data)
+	if err != nil {
+		return err
+	}
+
+	return app.store.Outbox.Enqueue(ctx, email)
+}
+
+// dispatchEmailsJob delivers the emails due in the outbox. Failed deliveries
+// are retried with exponential backoff and dead-lettered after maxAttempts.
+func (app *application) dispatchEmailsJob(ctx context.Context) error {
+	cfg := app.config.mail.outbox
+
+	emails, err := app.store.Outbox.ClaimPending(ctx, cfg.batchSize, cfg.lease)
+	if err != nil {
+		return err
+	}
+
+	isProdEnv := app.config.env == "production"
+
+	for _, email := range emails {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		err := app.deliverEmail(email, !isProdEnv)
+		if err == nil {
+			if err := app.store.Outbox.MarkSent(ctx, email.ID); err != nil {
+				return err
+			}
+			continue
+		}
+
+		attempts := email.Attempts + 1
+		dead := attempts >= cfg.maxAttempts
+
+		if dead {
+			app.logger.Errorw("email dead-lettered", "id", email.ID, "template", email.Template, "attempts", attempts, "error", err)
+		} else {
+			app.logger.Warnw("email delivery failed", "id", email.ID, "template", email.Template, "attempts", attempts, "error", err)
+		}
+
+		nextAttempt := time.Now().Add(outboxBackoff(cfg.backoff, cfg.maxBackoff, attempts))
+
+		if err := app.store.Outbox.MarkFailed(ctx, email.ID, err.Error(), nextAttempt, dead); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// purgeEmailsJob deletes the sent and dead-lettered emails older than the
+// retention period.
+func (app *application) purgeEmailsJob(ctx context.Context) error {
+	deleted, err := app.store.Outbox.DeleteOld(ctx, app.config.mail.outbox.retention)
+	if err != nil {
+		return err
+	}
+
+	if deleted > 0 {
+		app.logger.Infow("outbox purged", "emails", deleted)
+	}
+
+	return nil
+}
+
+func (app *application) deliverEmail(email store.OutboxEmail, isSandbox bool) error {
+	var vars map[string]any
+	if err := json.Unmarshal(email.Data, &vars); err != nil {
+		return err
+	}
+
+	status, err := app.mailer.Send(email.Template, email.Username, email.Email, vars, isSandbox)
+	if err != nil {
+		return err
+	}
+
+	if status >= http.StatusBadRequest {
+		return fmt.Errorf("mail provider responded with status %d", status)
+	}
+
+	app.logger.Infow("Email sent", "id", email.ID, "status code", status)
+
+	return nil
+}
+
+// outboxBackoff returns how long to wait before the next delivery attempt:
+// base doubled for each failed attempt, capped at max.
+func outboxBackoff(base, max time.Duration, attempts int) time.Duration {
+	backoff := base
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= max {
+			return max
+		}
+	}
+
+	return backoff
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/alejandro-cardenas-g/social/internal/mailer"
+	"github.com/alejandro-cardenas-g/social/internal/store"
+	"github.com/google/uuid"
+)
+
+type ForgotPasswordPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+// forgotPasswordHandler godoc
+//
+//	@Summary		Requests a password reset
+//	@Description	Sends a password reset link to the email if it belongs to an active user. The response is the same whether it does or not
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ForgotPasswordPayload	true	"User email"
+//	@Success		202		{string}	string					"Reset requested"
+//	@Failure		400		{object}	error
+//	@Failure		429		{object}	error
+//	@Router			/auth/password/forgot [post]
+func (app *application) forgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ForgotPasswordPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if allow, retryAfter := app.emailRateLimiter.Allow("password-reset:" + strings.ToLower(payload.Email)); !allow {
+		app.rateLimitExceededError(w, r, retryAfter.String())
+		return
+	}
+
+	// the lookup runs after responding, so neither the timing nor the status
+	// discloses which emails are registered
+	app.background("password reset", func(ctx context.Context) error {
+		return app.requestPasswordReset(ctx, payload.Email)
+	})
+
+	if err := app.jsonResponse(w, http.StatusAccepted, nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// requestPasswordReset stores a reset token for the user of email, if any,
+// and queues the email with the link in the same transaction.
+func (app *application) requestPasswordReset(ctx context.Context, email string) error {
+	user, err := app.store.Users.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	plainToken := uuid.New().String()
+
+	vars := struct {
+		Username  string
+		ResetURL  string
+		ExpiresIn string
+	}{
+		Username:  user.Username,
+		ResetURL:  fmt.Sprintf("%s/reset-password/%s", app.config.frontendURL, plainToken),
+		ExpiresIn: mailer.FormatDuration(app.config.mail.resetExp, user.Language),
+	}
+
+	resetEmail, err := newOutboxEmail(mailer.PasswordResetTemplate, user, vars)
+	if err != nil {
+		return err
+	}
+
+	return app.store.Users.CreatePasswordReset(ctx, user.ID, hashToken(plainToken), app.config.mail.resetExp, resetEmail)
+}
+
+type ResetPasswordPayload struct {
+	Token    string `json:"token" validate:"required,max=255"`
+	Password string `json:"password" validate:"required,max=255"`
+}
+
+// resetPasswordHandler godoc
+//
+//	@Summary		Resets a password
+//	@Description	Sets a new password using a reset token and revokes every existing session and personal access token
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ResetPasswordPayload	true	"Reset token and new password"
+//	@Success		204		{string}	string					"Password reset"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/auth/password/reset [post]
+func (app *application) resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ResetPasswordPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := app.config.auth.password.Validate(payload.Password); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	user := &store.User{}
+	if err := user.Password.Set(payload.Password, app.config.auth.hashing); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := app.store.Users.ResetPassword(ctx, payload.Token, user); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.revokeUserSessions(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type ChangePasswordPayload struct {
+	CurrentPassword string `json:"current_password" validate:"required,max=255"`
+	NewPassword     string `json:"new_password" validate:"required,max=255"`
+}
+
+// changePasswordHandler godoc
+//
+//	@Summary		Changes the user's password
+//	@Description	Sets a new password after checking the current one. Wrong current passwords count toward the login lockout. Every other session and every personal access token of the user is revoked and the user is notified by email
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ChangePasswordPayload	true	"Current and new password"
+//	@Success		204		{string}	string					"Password changed"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		429		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/password [put]
+func (app *application) changePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ChangePasswordPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := app.config.auth.password.Validate(payload.NewPassword); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	user := app.checkCurrentPassword(w, r, payload.CurrentPassword)
+	if user == nil {
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := user.Password.Set(payload.NewPassword, app.config.auth.hashing); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	vars := struct {
+		Username string
+		IP       string
+		ResetURL string
+	}{
+		Username: user.Username,
+		IP:       clientIP(r),
+		ResetURL: fmt.Sprintf("%s/forgot-password", app.config.frontendURL),
+	}
+
+	email, err := newOutboxEmail(mailer.PasswordChangedTemplate, user, vars)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.Users.ChangePassword(ctx, user, email); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.AccessTokens.DeleteByUser(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	currentSID, _ := getClaimsFromCtx(r)["sid"].(string)
+
+	sessions, err := app.store.Sessions.RevokeOthers(ctx, user.ID, currentSID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	for _, sessionID := range sessions {
+		if err := app.tokenRevocations().RevokeSession(ctx, sessionID, time.Now().Add(app.config.auth.token.exp)); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// passwordHashReportHandler godoc
+//
+//	@Summary		Reports the password hash schemes in use
+//	@Description	Counts the accounts per password hash algorithm and cost. Legacy hashes do not match the current configuration and are upgraded when their user logs in. Admin only
+//	@Tags			admin
+//	@Produce		json
+//	@Success		200	{object}	store.PasswordHashReport
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/password-hashes [get]
+func (app *application) passwordHashReportHandler(w http.ResponseWriter, r *http.Request) {
+	report, err := app.store.Users.PasswordHashReport(r.Context())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, report); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
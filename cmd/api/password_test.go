@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alejandro-cardenas-g/social/internal/auth"
+	ratelimiter "github.com/alejandro-cardenas-g/social/internal/rateLimiter"
+	"github.com/alejandro-cardenas-g/social/internal/store"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestChangePassword(t *testing.T) {
+	cfg := config{
+		auth: authConfig{
+			password: auth.PasswordPolicy{MinLength: 8, MaxLength: 64},
+			lockout:  lockoutConfig{maxAttempts: 2, maxIPAttempts: 50, window: time.Minute, lockout: time.Minute},
+		},
+	}
+
+	newApp := func(t *testing.T) (*application, *store.MockUserStore) {
+		user := &store.User{ID: mockUserID, Username: "ana", Email: "ana@example.com", IsActive: true}
+		if err := user.Password.Set("old password", store.DefaultPasswordHashing()); err != nil {
+			t.Fatal(err)
+		}
+
+		app := newTestApplication(t, cfg)
+		users := mockAuthenticatedUser(app, user)
+		users.On("GetByEmail", user.Email).Return(user, nil)
+		return app, users
+	}
+
+	put := func(t *testing.T, app *application, body string) int {
+		return authenticatedRequest(t, app, app.mount(), http.MethodPut, "/v1/users/me/password", strings.NewReader(body)).Code
+	}
+
+	t.Run("should enforce the password policy", func(t *testing.T) {
+		app, users := newApp(t)
+
+		checkResponseCode(t, http.StatusBadRequest, put(t, app, `{"current_password":"old password","new_password":"short"}`))
+		users.AssertNotCalled(t, "ChangePassword", mock.Anything, mock.Anything)
+	})
+
+	t.Run("should lock out wrong current passwords", func(t *testing.T) {
+		app, users := newApp(t)
+
+		for range cfg.auth.lockout.maxAttempts {
+			checkResponseCode(t, http.StatusUnauthorized, put(t, app, `{"current_password":"wrong password","new_password":"a long passphrase"}`))
+		}
+
+		checkResponseCode(t, http.StatusTooManyRequests, put(t, app, `{"current_password":"old password","new_password":"a long passphrase"}`))
+		users.AssertNotCalled(t, "ChangePassword", mock.Anything, mock.Anything)
+	})
+
+	t.Run("should change the password and revoke the access tokens", func(t *testing.T) {
+		app, users := newApp(t)
+
+		newPassword := mock.MatchedBy(func(user *store.User) bool {
+			return user.ID == mockUserID && user.Password.Compare("a long passphrase") == nil
+		})
+		notice := mock.MatchedBy(func(email *store.OutboxEmail) bool {
+			return email.Email == "ana@example.com"
+		})
+		users.On("ChangePassword", newPassword, notice).Return(nil).Once()
+
+		accessTokens := app.store.AccessTokens.(*store.MockAccessTokensStore)
+		accessTokens.On("DeleteByUser", mockUserID).Return(nil).Once()
+
+		checkResponseCode(t, http.StatusNoContent, put(t, app, `{"current_password":"old password","new_password":"a long passphrase"}`))
+
+		users.AssertExpectations(t)
+		accessTokens.AssertExpectations(t)
+	})
+}
+
+func TestForgotPassword(t *testing.T) {
+	app := newTestApplication(t, config{})
+	app.emailRateLimiter = ratelimiter.NewFixedWindowRateLimiter(1, time.Minute)
+	mux := app.mount()
+
+	users := app.store.Users.(*store.MockUserStore)
+
+	forgot := func(t *testing.T, email string) int {
+		body := strings.NewReader(`{"email":"` + email + `"}`)
+		req, err := http.NewRequest(http.MethodPost, "/v1/auth/password/forgot", body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		code := executeRequest(req, mux).Code
+		app.wg.Wait()
+		return code
+	}
+
+	t.Run("should queue a reset link for a registered email", func(t *testing.T) {
+		users.On("GetByEmail", "ana@example.com").Return(&store.User{ID: 5, Email: "ana@example.com"}, nil).Once()
+		resetEmail := mock.MatchedBy(func(email *store.OutboxEmail) bool {
+			return email.Email == "ana@example.com"
+		})
+		users.On("CreatePasswordReset", int64(5), mock.Anything, resetEmail).Return(nil).Once()
+
+		checkResponseCode(t, http.StatusAccepted, forgot(t, "ana@example.com"))
+	})
+
+	t.Run("should respond the same to an unknown email", func(t *testing.T) {
+		users.On("GetByEmail", "nobody@example.com").Return(nil, store.ErrNotFound).Once()
+
+		checkResponseCode(t, http.StatusAccepted, forgot(t, "nobody@example.com"))
+	})
+
+	t.Run("should respond the same when the lookup fails", func(t *testing.T) {
+		users.On("GetByEmail", "broken@example.com").Return(nil, errors.New("connection refused")).Once()
+
+		checkResponseCode(t, http.StatusAccepted, forgot(t, "broken@example.com"))
+	})
+
+	t.Run("should rate limit the requests for an email", func(t *testing.T) {
+		checkResponseCode(t, http.StatusTooManyRequests, forgot(t, "ANA@example.com"))
+	})
+
+	users.AssertExpectations(t)
+}
+
+func TestResetPassword(t *testing.T) {
+	cfg := config{
+		auth: authConfig{
+			password: auth.PasswordPolicy{MinLength: 8, MaxLength: 64},
+		},
+	}
+	app := newTestApplication(t, cfg)
+	mux := app.mount()
+
+	users := app.store.Users.(*store.MockUserStore)
+	refreshTokens := app.store.RefreshTokens.(*store.MockRefreshTokensStore)
+	accessTokens := app.store.AccessTokens.(*store.MockAccessTokensStore)
+
+	reset := func(t *testing.T, token string) int {
+		body := strings.NewReader(`{"token":"` + token + `","password":"a long passphrase"}`)
+		req, err := http.NewRequest(http.MethodPost, "/v1/auth/password/reset", body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should reset the password once and revoke the sessions and tokens", func(t *testing.T) {
+		users.On("ResetPassword", "valid", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+			args.Get(1).(*store.User).ID = 5
+		}).Once()
+		users.On("ResetPassword", "valid", mock.Anything).Return(store.ErrNotFound).Once()
+		refreshTokens.On("RevokeByUser", int64(5)).Return([]string{}, nil).Once()
+		accessTokens.On("DeleteByUser", int64(5)).Return(nil).Once()
+
+		checkResponseCode(t, http.StatusNoContent, reset(t, "valid"))
+		checkResponseCode(t, http.StatusNotFound, reset(t, "valid"))
+	})
+
+	t.Run("should reject an expired token", func(t *testing.T) {
+		users.On("ResetPassword", "expired", mock.Anything).Return(store.ErrNotFound).Once()
+
+		checkResponseCode(t, http.StatusNotFound, reset(t, "expired"))
+	})
+
+	users.AssertExpectations(t)
+	refreshTokens.AssertExpectations(t)
+	accessTokens.AssertExpectations(t)
+}
@@ -62,6 +62,12 @@ func (api *application) createPostHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	// the cached author has the previous posts count
+	if err := api.invalidateUser(ctx, user.ID); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+
 	if err := api.jsonResponse(w, http.StatusCreated, post); err != nil {
 		api.internalServerError(w, r, err)
 		return
@@ -84,7 +90,7 @@ func (api *application) createPostHandler(w http.ResponseWriter, r *http.Request
 func (api *application) getPostByIdHandler(w http.ResponseWriter, r *http.Request) {
 	post := getPostFromCtx(r)
 
-	comments, err := api.store.Comments.GetByPostID(r.Context(), post.ID)
+	comments, err := api.store.Comments.GetByPostID(r.Context(), post.ID, getUserFromCtx(r).ID)
 
 	if err != nil {
 		api.internalServerError(w, r, err)
@@ -193,6 +199,12 @@ func (api *application) deletePostHandler(w http.ResponseWriter, r *http.Request
 			return
 		}
 	}
+
+	if err := api.invalidateUser(ctx, getPostFromCtx(r).UserId); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -218,11 +230,34 @@ func (api *application) postsContextMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		allowed, err := api.canViewPost(ctx, getUserFromCtx(r), post)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			api.internalServerError(w, r, err)
+			return
+		}
+
+		if !allowed {
+			// do not disclose that the post exists
+			api.notFoundError(w, r, store.ErrNotFound)
+			return
+		}
+
 		ctx = context.WithValue(ctx, postCtx, post)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// canViewPost reports whether user may see post. Posts of private accounts
+// are only visible to their approved followers and to moderators.
+func (api *application) canViewPost(ctx context.Context, user *store.User, post *store.Post) (bool, error) {
+	allowed, err := api.store.Followers.CanView(ctx, user.ID, post.UserId)
+	if err != nil || allowed {
+		return allowed, err
+	}
+
+	return api.checkRolePrecedence(ctx, user, "moderator")
+}
+
 func getPostFromCtx(r *http.Request) *store.Post {
 	post := r.Context().Value(postCtx).(*store.Post)
 	return post
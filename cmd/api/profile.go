@@ -0,0 +1,238 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/alejandro-cardenas-g/social/internal/mailer"
+	"github.com/alejandro-cardenas-g/social/internal/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type UpdateProfilePayload struct {
+	Username *string `json:"username" validate:"omitnil,min=1,max=100"`
+	Email    *string `json:"email" validate:"omitnil,email,max=255"`
+	// current_password is required to change the email
+	CurrentPassword *string `json:"current_password" validate:"omitnil,max=255"`
+	DisplayName     *string `json:"display_name" validate:"omitnil,max=100"`
+	Bio             *string `json:"bio" validate:"omitnil,max=500"`
+	// an empty avatar_url removes the avatar
+	AvatarURL *string `json:"avatar_url" validate:"omitnil,max=2048,eq=|http_url"`
+	Location  *string `json:"location" validate:"omitnil,max=100"`
+	IsPrivate *bool   `json:"is_private"`
+}
+
+// getProfileHandler godoc
+//
+//	@Summary		Fetches the user's profile
+//	@Description	Fetches the profile of the authenticated user
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{object}	store.User
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me [get]
+func (app *application) getProfileHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// updateProfileHandler godoc
+//
+//	@Summary		Updates the user's profile
+//	@Description	Updates the given fields of the authenticated user's profile. Changing the email requires the current password, and the new email is only applied once it is confirmed through the link sent to it. The current address is notified of the change. Nothing is updated when the new email is taken. Making the account public approves its pending follow requests
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		UpdateProfilePayload	true	"Profile fields"
+//	@Success		200		{object}	store.User
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		429		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me [patch]
+func (app *application) updateProfileHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UpdateProfilePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	// work on a copy so a failed update does not leak into the cached user
+	user := *getUserFromCtx(r)
+	ctx := r.Context()
+
+	var change *store.EmailChange
+	if payload.Email != nil && !strings.EqualFold(*payload.Email, user.Email) {
+		if payload.CurrentPassword == nil {
+			app.badRequestError(w, r, errors.New("current_password is required to change the email"))
+			return
+		}
+
+		if app.checkCurrentPassword(w, r, *payload.CurrentPassword) == nil {
+			return
+		}
+
+		var err error
+		change, err = app.newEmailChange(r, &user, *payload.Email)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	if payload.Username != nil {
+		user.Username = *payload.Username
+	}
+	if payload.DisplayName != nil {
+		user.DisplayName = *payload.DisplayName
+	}
+	if payload.Bio != nil {
+		user.Bio = *payload.Bio
+	}
+	if payload.AvatarURL != nil {
+		user.AvatarURL = *payload.AvatarURL
+	}
+	if payload.Location != nil {
+		user.Location = *payload.Location
+	}
+	if payload.IsPrivate != nil {
+		user.IsPrivate = *payload.IsPrivate
+	}
+
+	requesterIDs, err := app.store.Users.UpdateProfile(ctx, &user, change)
+	if err != nil {
+		switch err {
+		case store.ErrDuplicateUsername, store.ErrDuplicateEmail:
+			app.conflictError(w, r, err)
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.invalidateUser(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// requests approved by going public are new follows of each requester
+	for _, requesterID := range requesterIDs {
+		if err := app.invalidateUser(ctx, requesterID); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if err := app.invalidateSuggestions(ctx, requesterID); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// newEmailChange builds the confirmation link sent to newEmail and the notice
+// sent to the current address. The email of the user only changes once the
+// link is followed.
+func (app *application) newEmailChange(r *http.Request, user *store.User, newEmail string) (*store.EmailChange, error) {
+	plainToken := uuid.New().String()
+
+	vars := struct {
+		Username   string
+		ConfirmURL string
+		ExpiresIn  string
+	}{
+		Username:   user.Username,
+		ConfirmURL: fmt.Sprintf("%s/confirm-email/%s", app.config.frontendURL, plainToken),
+		ExpiresIn:  mailer.FormatDuration(app.config.mail.emailChangeExp, user.Language),
+	}
+
+	recipient := *user
+	recipient.Email = newEmail
+
+	confirmation, err := newOutboxEmail(mailer.EmailChangeTemplate, &recipient, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	noticeVars := struct {
+		Username string
+		NewEmail string
+		IP       string
+		ResetURL string
+	}{
+		Username: user.Username,
+		NewEmail: newEmail,
+		IP:       clientIP(r),
+		ResetURL: fmt.Sprintf("%s/forgot-password", app.config.frontendURL),
+	}
+
+	notice, err := newOutboxEmail(mailer.EmailChangeNoticeTemplate, user, noticeVars)
+	if err != nil {
+		return nil, err
+	}
+
+	return &store.EmailChange{
+		NewEmail:     newEmail,
+		Token:        hashToken(plainToken),
+		Exp:          app.config.mail.emailChangeExp,
+		Confirmation: confirmation,
+		Notice:       notice,
+	}, nil
+}
+
+// confirmEmailChangeHandler godoc
+//
+//	@Summary		Confirms an email change
+//	@Description	Applies a pending email change with the token sent to the new address
+//	@Tags			users
+//	@Produce		json
+//	@Param			token	path		string	true	"Email change token"
+//	@Success		204		{string}	string	"Email changed"
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/users/email/confirm/{token} [put]
+func (app *application) confirmEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+	ctx := r.Context()
+
+	user, err := app.store.Users.ConfirmEmailChange(ctx, token)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+		case store.ErrDuplicateEmail:
+			app.conflictError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.invalidateUser(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alejandro-cardenas-g/social/internal/mailer"
+	"github.com/alejandro-cardenas-g/social/internal/store"
+	"github.com/alejandro-cardenas-g/social/internal/store/cache"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestUpdateProfile(t *testing.T) {
+	withRedis := config{
+		redisCfg: redisConfig{
+			enabled: true,
+		},
+	}
+	app := newTestApplication(t, withRedis)
+	users := mockAuthenticatedUser(app, &store.User{ID: mockUserID})
+	mux := app.mount()
+
+	patch := func(t *testing.T, body string) int {
+		return authenticatedRequest(t, app, mux, http.MethodPatch, "/v1/users/me", strings.NewReader(body)).Code
+	}
+
+	t.Run("should invalidate the cached user", func(t *testing.T) {
+		mockCacheStore := app.cacheStorage.Users.(*cache.UsersMockStore)
+
+		mockCacheStore.On("Get", mock.Anything).Return(nil, nil)
+		mockCacheStore.On("Set", mock.Anything).Return(nil)
+		mockCacheStore.On("Delete", mock.Anything).Return(nil)
+		users.On("UpdateProfile", mock.Anything, (*store.EmailChange)(nil)).Return(nil, nil).Once()
+
+		checkResponseCode(t, http.StatusOK, patch(t, `{"bio":"gopher","avatar_url":""}`))
+
+		mockCacheStore.AssertNumberOfCalls(t, "Delete", 1)
+		mockCacheStore.Calls = nil // Reset mock expectations
+		users.AssertExpectations(t)
+	})
+
+	t.Run("should invalidate the requesters approved by going public", func(t *testing.T) {
+		mockCacheStore := app.cacheStorage.Users.(*cache.UsersMockStore)
+		mockSuggestions := app.cacheStorage.Suggestions.(*cache.SuggestionsMockStore)
+
+		mockSuggestions.On("Delete", int64(2)).Return(nil).Once()
+		mockSuggestions.On("Delete", int64(3)).Return(nil).Once()
+		users.On("UpdateProfile", mock.Anything, (*store.EmailChange)(nil)).Return([]int64{2, 3}, nil).Once()
+
+		checkResponseCode(t, http.StatusOK, patch(t, `{"is_private":false}`))
+
+		mockCacheStore.AssertCalled(t, "Delete", mockUserID)
+		mockCacheStore.AssertCalled(t, "Delete", int64(2))
+		mockCacheStore.AssertCalled(t, "Delete", int64(3))
+		mockCacheStore.Calls = nil // Reset mock expectations
+		mockSuggestions.AssertExpectations(t)
+		users.AssertExpectations(t)
+	})
+
+	t.Run("should reject an invalid avatar url", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, patch(t, `{"avatar_url":"not a url"}`))
+	})
+
+	t.Run("should reject an empty username", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, patch(t, `{"username":""}`))
+	})
+}
+
+func TestChangeEmail(t *testing.T) {
+	cfg := config{
+		auth: authConfig{
+			lockout: lockoutConfig{maxAttempts: 2, maxIPAttempts: 50, window: time.Minute, lockout: time.Minute},
+		},
+	}
+
+	user := &store.User{ID: 1, Username: "ana", Email: "ana@example.com", IsActive: true}
+	if err := user.Password.Set("correct horse", store.DefaultPasswordHashing()); err != nil {
+		t.Fatal(err)
+	}
+
+	newApp := func(t *testing.T) (*application, *store.MockUserStore) {
+		app := newTestApplication(t, cfg)
+		users := mockAuthenticatedUser(app, user)
+		users.On("GetByEmail", user.Email).Return(user, nil)
+		return app, users
+	}
+
+	patch := func(t *testing.T, app *application, body string) int {
+		return authenticatedRequest(t, app, app.mount(), http.MethodPatch, "/v1/users/me", strings.NewReader(body)).Code
+	}
+
+	t.Run("should require the current password", func(t *testing.T) {
+		app, users := newApp(t)
+
+		checkResponseCode(t, http.StatusBadRequest, patch(t, app, `{"email":"new@example.com"}`))
+		users.AssertNotCalled(t, "UpdateProfile", mock.Anything, mock.Anything)
+	})
+
+	t.Run("should lock out wrong current passwords", func(t *testing.T) {
+		app, users := newApp(t)
+
+		for range cfg.auth.lockout.maxAttempts {
+			checkResponseCode(t, http.StatusUnauthorized, patch(t, app, `{"email":"new@example.com","current_password":"wrong horse"}`))
+		}
+
+		checkResponseCode(t, http.StatusTooManyRequests, patch(t, app, `{"email":"new@example.com","current_password":"correct horse"}`))
+		users.AssertNotCalled(t, "UpdateProfile", mock.Anything, mock.Anything)
+	})
+
+	t.Run("should confirm the new address and notify the current one", func(t *testing.T) {
+		app, users := newApp(t)
+
+		users.On("UpdateProfile", user, mock.MatchedBy(func(change *store.EmailChange) bool {
+			return change != nil &&
+				change.NewEmail == "new@example.com" &&
+				change.Confirmation.Template == mailer.EmailChangeTemplate &&
+				change.Confirmation.Email == "new@example.com" &&
+				change.Notice.Template == mailer.EmailChangeNoticeTemplate &&
+				change.Notice.Email == "ana@example.com"
+		})).Return(nil, nil).Once()
+
+		checkResponseCode(t, http.StatusOK, patch(t, app, `{"email":"new@example.com","current_password":"correct horse"}`))
+		users.AssertExpectations(t)
+	})
+
+	t.Run("should report a taken email", func(t *testing.T) {
+		app, users := newApp(t)
+
+		users.On("UpdateProfile", mock.Anything, mock.Anything).Return(nil, store.ErrDuplicateEmail).Once()
+
+		checkResponseCode(t, http.StatusConflict, patch(t, app, `{"bio":"gopher","email":"taken@example.com","current_password":"correct horse"}`))
+		users.AssertExpectations(t)
+	})
+}
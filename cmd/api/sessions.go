@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/alejandro-cardenas-g/social/internal/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type SessionResponse struct {
+	store.Session
+	// Current is set on the session of the token making the request
+	Current bool `json:"current"`
+}
+
+// listSessionsHandler godoc
+//
+//	@Summary		Lists the user's sessions
+//	@Description	Lists the devices the user is logged in on. last_seen_at is updated about once a minute while the session is used
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{object}	[]SessionResponse
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/sessions [get]
+func (app *application) listSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	currentSID, _ := getClaimsFromCtx(r)["sid"].(string)
+
+	sessions, err := app.store.Sessions.GetByUserID(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	res := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		res = append(res, SessionResponse{
+			Session: session,
+			Current: session.ID == currentSID,
+		})
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, res); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// revokeSessionHandler godoc
+//
+//	@Summary		Revokes a session
+//	@Description	Logs the user out of a session. Its access and refresh tokens stop working immediately
+//	@Tags			users
+//	@Produce		json
+//	@Param			sessionID	path		string	true	"Session ID"
+//	@Success		204			{string}	string	"Session revoked"
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/sessions/{sessionID} [delete]
+func (app *application) revokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionID")
+	if _, err := uuid.Parse(sessionID); err != nil {
+		app.notFoundError(w, r, store.ErrNotFound)
+		return
+	}
+
+	user := getUserFromCtx(r)
+	ctx := r.Context()
+
+	if err := app.store.Sessions.Revoke(ctx, sessionID, user.ID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.tokenRevocations().RevokeSession(ctx, sessionID, time.Now().Add(app.config.auth.token.exp)); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sessionTouchInterval is how often a session in use has its last_seen_at
+// updated.
+const sessionTouchInterval = time.Minute
+
+// sessionThrottle remembers when this instance last touched each session.
+// The zero value is ready to use.
+type sessionThrottle struct {
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	lastPrune time.Time
+}
+
+// due reports whether the session should be touched at now, and records
+// that it was if so.
+func (t *sessionThrottle) due(sessionID string, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.seen == nil {
+		t.seen = map[string]time.Time{}
+	}
+
+	if now.Sub(t.seen[sessionID]) < sessionTouchInterval {
+		return false
+	}
+
+	if now.Sub(t.lastPrune) >= sessionTouchInterval {
+		for id, at := range t.seen {
+			if now.Sub(at) >= sessionTouchInterval {
+				delete(t.seen, id)
+			}
+		}
+		t.lastPrune = now
+	}
+
+	t.seen[sessionID] = now
+	return true
+}
+
+// touchSession updates the last_seen_at of the session a request is made
+// with. Failing to do so does not fail the request.
+func (app *application) touchSession(ctx context.Context, sessionID string) {
+	if sessionID == "" || !app.sessionTouches.due(sessionID, time.Now()) {
+		return
+	}
+
+	if err := app.store.Sessions.Touch(ctx, sessionID); err != nil {
+		app.logger.Warnw("failed to update session last seen", "session", sessionID, "error", err)
+	}
+}
+
+// truncate shortens s to at most n bytes without splitting a character.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return s[:n]
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alejandro-cardenas-g/social/internal/store"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSessions(t *testing.T) {
+	app := newTestApplication(t, config{
+		auth: authConfig{token: tokenConfig{exp: time.Hour, iss: "test"}},
+	})
+	mockAuthenticatedUser(app, &store.User{ID: mockUserID, IsActive: true})
+	mux := app.mount()
+
+	current := uuid.New().String()
+	other := uuid.New().String()
+
+	sessions := app.store.Sessions.(*store.MockSessionsStore)
+	sessions.On("Touch", current).Return(nil).Once()
+	sessions.On("Touch", other).Return(nil).Once()
+	sessions.On("GetByUserID", int64(1)).Return([]store.Session{{ID: current, UserID: 1}, {ID: other, UserID: 1}}, nil)
+	sessions.On("Revoke", other, int64(1)).Return(nil)
+	sessions.On("Revoke", mock.MatchedBy(func(id string) bool { return id != other }), int64(1)).Return(store.ErrNotFound)
+
+	currentToken, err := app.generateAccessToken(1, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherToken, err := app.generateAccessToken(1, other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(t *testing.T, method, path, token string) *http.Response {
+		req, err := http.NewRequest(method, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		return executeRequest(req, mux).Result()
+	}
+
+	t.Run("should list the sessions and mark the current one", func(t *testing.T) {
+		res := request(t, http.MethodGet, "/v1/users/me/sessions", currentToken)
+		checkResponseCode(t, http.StatusOK, res.StatusCode)
+
+		var body struct {
+			Data []SessionResponse `json:"data"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(body.Data) != 2 {
+			t.Fatalf("expected 2 sessions, got %d", len(body.Data))
+		}
+		if !body.Data[0].Current || body.Data[1].Current {
+			t.Errorf("expected only session %s to be current, got %+v", current, body.Data)
+		}
+	})
+
+	t.Run("should accept the token of a session before it is revoked", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodGet, "/v1/users/me", otherToken).StatusCode)
+	})
+
+	t.Run("should revoke a session", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, request(t, http.MethodDelete, "/v1/users/me/sessions/"+other, currentToken).StatusCode)
+	})
+
+	t.Run("should reject the token of a revoked session", func(t *testing.T) {
+		checkResponseCode(t, http.StatusUnauthorized, request(t, http.MethodGet, "/v1/users/me", otherToken).StatusCode)
+	})
+
+	t.Run("should not find a session of someone else", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodDelete, "/v1/users/me/sessions/"+uuid.New().String(), currentToken).StatusCode)
+	})
+
+	t.Run("should not find an invalid session id", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodDelete, "/v1/users/me/sessions/not-a-session", currentToken).StatusCode)
+	})
+
+	sessions.AssertExpectations(t)
+	sessions.AssertNumberOfCalls(t, "Touch", 2)
+	sessions.AssertNumberOfCalls(t, "Revoke", 2)
+}
+
+func TestSessionThrottle(t *testing.T) {
+	var throttle sessionThrottle
+	now := time.Now()
+
+	if !throttle.due("a", now) {
+		t.Error("expected the first use of a session to be due")
+	}
+	if throttle.due("a", now.Add(30*time.Second)) {
+		t.Error("expected a session used again within a minute not to be due")
+	}
+	if !throttle.due("b", now.Add(30*time.Second)) {
+		t.Error("expected another session to be due")
+	}
+	if !throttle.due("a", now.Add(time.Minute)) {
+		t.Error("expected a session to be due again after a minute")
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/alejandro-cardenas-g/social/internal/store"
+)
+
+// GetSuggestions godoc
+//
+//	@Summary		Suggests users to follow
+//	@Description	Suggests users followed by the ones the authenticated user follows, posting with the same tags, or popular, best first
+//	@Tags			users
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit, up to 50"
+//	@Success		200		{object}	[]store.Suggestion
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/suggestions [get]
+func (app *application) getSuggestionsHandler(w http.ResponseWriter, r *http.Request) {
+	limit := 10
+	if qs := r.URL.Query().Get("limit"); qs != "" {
+		l, err := strconv.Atoi(qs)
+		if err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+		limit = l
+	}
+
+	if err := Validate.Var(limit, "gte=1,lte=50"); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	suggestions, err := app.getSuggestions(r.Context(), getUserFromCtx(r).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, suggestions); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// getSuggestions returns up to store.MaxSuggestions suggestions, cached
+// per user when redis is enabled.
+func (app *application) getSuggestions(ctx context.Context, userID int64) ([]store.Suggestion, error) {
+	if !app.config.redisCfg.enabled {
+		return app.store.Suggestions.Get(ctx, userID)
+	}
+
+	cached, err := app.cacheStorage.Suggestions.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if cached != nil {
+		return cached, nil
+	}
+
+	suggestions, err := app.store.Suggestions.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := app.cacheStorage.Suggestions.Set(ctx, userID, suggestions); err != nil {
+		return nil, err
+	}
+
+	return suggestions, nil
+}
+
+func (app *application) invalidateSuggestions(ctx context.Context, userID int64) error {
+	if !app.config.redisCfg.enabled {
+		return nil
+	}
+
+	return app.cacheStorage.Suggestions.Delete(ctx, userID)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/alejandro-cardenas-g/social/internal/store"
+	"github.com/alejandro-cardenas-g/social/internal/store/cache"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetSuggestions(t *testing.T) {
+	withRedis := config{
+		redisCfg: redisConfig{
+			enabled: true,
+		},
+	}
+	app := newTestApplication(t, withRedis)
+	mockAuthenticatedUser(app, &store.User{ID: mockUserID})
+	mux := app.mount()
+
+	mockUsersCache := app.cacheStorage.Users.(*cache.UsersMockStore)
+	mockUsersCache.On("Get", mock.Anything).Return(nil, nil)
+	mockUsersCache.On("Set", mock.Anything).Return(nil)
+	mockUsersCache.On("Delete", mock.Anything).Return(nil)
+
+	mockCacheStore := app.cacheStorage.Suggestions.(*cache.SuggestionsMockStore)
+	suggestions := app.store.Suggestions.(*store.MockSuggestionsStore)
+	followers := app.store.Followers.(*store.MockFollowersStore)
+
+	request := func(t *testing.T, method, path string) int {
+		return authenticatedRequest(t, app, mux, method, path, nil).Code
+	}
+
+	t.Run("should cache the suggestions of the user", func(t *testing.T) {
+		suggested := []store.Suggestion{{ID: 2, Username: "ana", MutualFollows: 1}}
+
+		mockCacheStore.On("Get", mockUserID).Return(nil, nil).Once()
+		suggestions.On("Get", mockUserID).Return(suggested, nil).Once()
+		mockCacheStore.On("Set", mockUserID, suggested).Return(nil).Once()
+
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodGet, "/v1/users/me/suggestions"))
+	})
+
+	t.Run("should reject an invalid limit", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, request(t, http.MethodGet, "/v1/users/me/suggestions?limit=abc"))
+		checkResponseCode(t, http.StatusBadRequest, request(t, http.MethodGet, "/v1/users/me/suggestions?limit=100"))
+	})
+
+	t.Run("should invalidate the suggestions of a follow requester", func(t *testing.T) {
+		followers.On("Follow", mockUserID, int64(2)).Return(true, nil).Once()
+		mockCacheStore.On("Delete", mockUserID).Return(nil).Once()
+
+		checkResponseCode(t, http.StatusAccepted, request(t, http.MethodPut, "/v1/users/2/follow"))
+	})
+
+	t.Run("should invalidate the suggestions of both users on approval", func(t *testing.T) {
+		followers.On("ApproveRequest", mockUserID, int64(3)).Return(nil).Once()
+		mockCacheStore.On("Delete", int64(3)).Return(nil).Once()
+		mockCacheStore.On("Delete", mockUserID).Return(nil).Once()
+
+		checkResponseCode(t, http.StatusNoContent, request(t, http.MethodPut, "/v1/users/me/follow-requests/3/approve"))
+	})
+
+	mockCacheStore.AssertExpectations(t)
+	suggestions.AssertExpectations(t)
+	followers.AssertExpectations(t)
+}
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/alejandro-cardenas-g/social/internal/auth"
+	ratelimiter "github.com/alejandro-cardenas-g/social/internal/rateLimiter"
 	"github.com/alejandro-cardenas-g/social/internal/store"
 	"github.com/alejandro-cardenas-g/social/internal/store/cache"
 	"go.uber.org/zap"
@@ -20,15 +23,47 @@ func newTestApplication(t *testing.T, cfg config) *application {
 	testAuth := auth.NewTestAuthenticator()
 
 	return &application{
-		logger:        logger,
-		store:         mockStore,
-		cacheStorage:  cacheStore,
-		authenticator: testAuth,
-		config:        cfg,
+		logger:           logger,
+		store:            mockStore,
+		cacheStorage:     cacheStore,
+		authenticator:    testAuth,
+		config:           cfg,
+		emailRateLimiter: ratelimiter.NewFixedWindowRateLimiter(100, time.Minute),
 	}
 
 }
 
+// mockUserID is the subject of the tokens of the test authenticator.
+const mockUserID int64 = 1
+
+// mockAuthenticatedUser makes the mock users store return user for the
+// tokens of the test authenticator, and returns the store so tests can set
+// further expectations.
+func mockAuthenticatedUser(app *application, user *store.User) *store.MockUserStore {
+	users := app.store.Users.(*store.MockUserStore)
+	users.On("GetByID", mockUserID).Return(user, nil)
+	return users
+}
+
+// authenticatedRequest sends a request to mux with a token of the test
+// authenticator.
+func authenticatedRequest(t *testing.T, app *application, mux http.Handler, method, path string, body io.Reader) *httptest.ResponseRecorder {
+	t.Helper()
+
+	token, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(method, path, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	return executeRequest(req, mux)
+}
+
 func executeRequest(req *http.Request, mux http.Handler) *httptest.ResponseRecorder {
 	rr := httptest.NewRecorder()
 	mux.ServeHTTP(rr, req)
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alejandro-cardenas-g/social/internal/auth"
+	"github.com/alejandro-cardenas-g/social/internal/store"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const recoveryCodesCount = 10
+
+var errInvalidTwoFactorCode = errors.New("invalid two-factor code")
+
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+	ExpiresIn         int64  `json:"expires_in"`
+}
+
+type TwoFactorEnrollmentResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type TwoFactorCodePayload struct {
+	Code string `json:"code" validate:"required,numeric,len=6"`
+}
+
+type TwoFactorChallengePayload struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code" validate:"required_without=RecoveryCode,omitempty,numeric,len=6"`
+	RecoveryCode   string `json:"recovery_code" validate:"required_without=Code,omitempty,max=20"`
+}
+
+// enrollTwoFactorHandler godoc
+//
+//	@Summary		Starts 2FA enrollment
+//	@Description	Generates a TOTP secret for the user. It is not enforced until verified
+//	@Tags			auth
+//	@Produce		json
+//	@Success		201	{object}	TwoFactorEnrollmentResponse
+//	@Failure		401	{object}	error
+//	@Failure		409	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/auth/2fa/enroll [post]
+func (app *application) enrollTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	if user.TwoFactorEnabled {
+		app.conflictError(w, r, errors.New("two-factor authentication is already enabled"))
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.Users.SetTOTPSecret(r.Context(), user.ID, secret); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	res := &TwoFactorEnrollmentResponse{
+		Secret:     secret,
+		OTPAuthURI: auth.TOTPURI(secret, app.config.auth.twoFactor.issuer, user.Email),
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, res); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// verifyTwoFactorHandler godoc
+//
+//	@Summary		Confirms 2FA enrollment
+//	@Description	Enables 2FA once the user proves the authenticator works and returns one-time recovery codes
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		TwoFactorCodePayload	true	"Current TOTP code"
+//	@Success		200		{object}	[]string				"Recovery codes"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/auth/2fa/verify [post]
+func (app *application) verifyTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var payload TwoFactorCodePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+	ctx := r.Context()
+
+	secret, err := app.store.Users.GetTOTPSecret(ctx, user.ID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.badRequestError(w, r, errors.New("two-factor enrollment has not been started"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.useTOTPCode(ctx, user.ID, secret, payload.Code); err != nil {
+		switch err {
+		case errInvalidTwoFactorCode:
+			app.badRequestError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.Users.EnableTOTP(ctx, user.ID, hashes); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.invalidateUser(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, codes); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// disableTwoFactorHandler godoc
+//
+//	@Summary		Disables 2FA
+//	@Description	Disables 2FA for the user unless their role requires it
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		TwoFactorCodePayload	true	"Current TOTP code"
+//	@Success		204		{string}	string					"2FA disabled"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/auth/2fa [delete]
+func (app *application) disableTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var payload TwoFactorCodePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+	ctx := r.Context()
+
+	if app.twoFactorRequired(user) {
+		app.twoFactorRequiredError(w, r)
+		return
+	}
+
+	if !user.TwoFactorEnabled {
+		app.badRequestError(w, r, errors.New("two-factor authentication is not enabled"))
+		return
+	}
+
+	secret, err := app.store.Users.GetTOTPSecret(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.useTOTPCode(ctx, user.ID, secret, payload.Code); err != nil {
+		switch err {
+		case errInvalidTwoFactorCode:
+			app.badRequestError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.store.Users.DisableTOTP(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.invalidateUser(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// twoFactorChallengeHandler godoc
+//
+//	@Summary		Solves a 2FA challenge
+//	@Description	Exchanges a login challenge and a TOTP or recovery code for tokens
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		TwoFactorChallengePayload	true	"Challenge and code"
+//	@Success		201		{object}	TokenResponse
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		429		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/auth/2fa/challenge [post]
+func (app *application) twoFactorChallengeHandler(w http.ResponseWriter, r *http.Request) {
+	var payload TwoFactorChallengePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	jwtToken, err := app.authenticator.ValidateToken(payload.ChallengeToken)
+	if err != nil {
+		app.unauthorizedError(w, r, err)
+		return
+	}
+
+	claims := jwtToken.Claims.(jwt.MapClaims)
+
+	if typ, _ := claims["typ"].(string); typ != challengeTokenType {
+		app.unauthorizedError(w, r, fmt.Errorf("token is not a two-factor challenge"))
+		return
+	}
+
+	userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
+	if err != nil {
+		app.unauthorizedError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	revoked, err := app.isTokenRevoked(ctx, claims, userID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if revoked {
+		app.unauthorizedError(w, r, fmt.Errorf("two-factor challenge has already been used"))
+		return
+	}
+
+	user, err := app.store.Users.GetByID(ctx, userID)
+	if err != nil {
+		app.unauthorizedError(w, r, err)
+		return
+	}
+
+	lockedFor, err := app.loginLockedFor(ctx, r, accountAttemptsKey(user.Email), twoFactorAttemptsKey(user.ID))
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if lockedFor > 0 {
+		app.loginLockedError(w, r, strconv.Itoa(int(lockedFor.Seconds())))
+		return
+	}
+
+	if payload.RecoveryCode != "" {
+		err = app.store.Users.UseRecoveryCode(ctx, user.ID, hashToken(normalizeRecoveryCode(payload.RecoveryCode)))
+	} else {
+		var secret string
+		secret, err = app.store.Users.GetTOTPSecret(ctx, user.ID)
+		if err == nil {
+			err = app.useTOTPCode(ctx, user.ID, secret, payload.Code)
+		}
+	}
+
+	if err != nil {
+		switch err {
+		case store.ErrNotFound, errInvalidTwoFactorCode:
+			app.failedLogin(w, r, twoFactorAttemptsKey(user.ID), user, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// a challenge is solved once, it cannot be exchanged for tokens again
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		app.unauthorizedError(w, r, fmt.Errorf("two-factor challenge has no expiration"))
+		return
+	}
+
+	if err := app.tokenRevocations().Revoke(ctx, claims["jti"].(string), exp.Time); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.resetLoginFailures(ctx, user); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	tokens, err := app.issueTokens(r, user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, tokens); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// useTOTPCode checks code against the user's secret and marks its time step
+// as used. Wrong and replayed codes return errInvalidTwoFactorCode.
+func (app *application) useTOTPCode(ctx context.Context, userID int64, secret, code string) error {
+	step, ok := auth.MatchTOTP(secret, code, time.Now())
+	if !ok {
+		return errInvalidTwoFactorCode
+	}
+
+	if err := app.store.Users.UseTOTPStep(ctx, userID, step); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return errInvalidTwoFactorCode
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (app *application) twoFactorRequired(user *store.User) bool {
+	level := app.config.auth.twoFactor.requiredLevel
+	return level > 0 && user.Role.Level >= level
+}
+
+// generateRecoveryCodes returns the codes to show to the user once and the
+// hashes to store.
+func generateRecoveryCodes() ([]string, []string, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+
+	codes := make([]string, 0, recoveryCodesCount)
+	hashes := make([]string, 0, recoveryCodesCount)
+
+	for i := 0; i < recoveryCodesCount; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+
+		code := strings.ToLower(encoding.EncodeToString(b))
+
+		codes = append(codes, code[:4]+"-"+code[4:])
+		hashes = append(hashes, hashToken(code))
+	}
+
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.ReplaceAll(code, "-", "")
+}
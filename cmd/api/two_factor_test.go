@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alejandro-cardenas-g/social/internal/auth"
+	"github.com/alejandro-cardenas-g/social/internal/store"
+)
+
+func TestTwoFactorChallenge(t *testing.T) {
+	cfg := config{
+		auth: authConfig{
+			twoFactor: twoFactorConfig{challengeExp: time.Minute},
+			lockout:   lockoutConfig{maxAttempts: 5, maxIPAttempts: 50},
+		},
+	}
+	app := newTestApplication(t, cfg)
+	mux := app.mount()
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users := mockAuthenticatedUser(app, &store.User{ID: mockUserID, Email: "ana@example.com", TwoFactorEnabled: true})
+	users.On("GetTOTPSecret", mockUserID).Return(secret, nil)
+
+	now := time.Now()
+	step := now.Unix() / int64(auth.TOTPPeriod.Seconds())
+
+	code, err := auth.GenerateTOTPCode(secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	solve := func(t *testing.T, challenge string) int {
+		body := strings.NewReader(`{"challenge_token":"` + challenge + `","code":"` + code + `"}`)
+		req, err := http.NewRequest(http.MethodPost, "/v1/auth/2fa/challenge", body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should solve a challenge only once", func(t *testing.T) {
+		challenge, err := app.generateChallengeToken(mockUserID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		users.On("UseTOTPStep", mockUserID, step).Return(nil).Once()
+
+		checkResponseCode(t, http.StatusCreated, solve(t, challenge))
+		checkResponseCode(t, http.StatusUnauthorized, solve(t, challenge))
+	})
+
+	t.Run("should reject a code whose time step was already used", func(t *testing.T) {
+		challenge, err := app.generateChallengeToken(mockUserID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		users.On("UseTOTPStep", mockUserID, step).Return(store.ErrNotFound).Once()
+
+		checkResponseCode(t, http.StatusUnauthorized, solve(t, challenge))
+	})
+
+	users.AssertExpectations(t)
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/alejandro-cardenas-g/social/internal/store"
 	"github.com/go-chi/chi/v5"
@@ -13,6 +16,15 @@ type usersKey string
 
 const userCtx usersKey = "user"
 
+// PublicUser is a user as other users see it. The fields below shadow the
+// account settings and the pending deletion of the embedded user and are
+// never set, so they are left out of the response.
+type PublicUser struct {
+	*store.User
+	TwoFactorEnabled    bool    `json:"two_factor_enabled,omitempty"`
+	DeletionScheduledAt *string `json:"deletion_scheduled_at,omitempty"`
+}
+
 // GetUser godoc
 //
 //	@summary		Fetches an user profile.
@@ -21,7 +33,7 @@ const userCtx usersKey = "user"
 //	@Accept			json
 //	@Produce		json
 //	@Param			id	path		int	true	"User ID"
-//	@Success		200	{object}	store.User
+//	@Success		200	{object}	PublicUser
 //	@Failure		400	{object}	error
 //	@Failure		404	{object}	error
 //	@Failure		500	{object}	error
@@ -48,7 +60,7 @@ func (api *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := api.jsonResponse(w, http.StatusOK, user); err != nil {
+	if err := api.jsonResponse(w, http.StatusOK, PublicUser{User: user}); err != nil {
 		api.internalServerError(w, r, err)
 	}
 }
@@ -56,13 +68,15 @@ func (api *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 // FollowUser godoc
 //
 //	@summary		Follows an user
-//	@Description	Follows an user profile by ID
+//	@Description	Follows an user profile by ID. Following a private account sends a follow request instead
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
 //	@Param			id	path		int	true	"User ID"
 //	@Success		204	{object}	string  "User followed"
+//	@Success		202	{object}	string  "Follow requested"
 //	@Failure		400	{object}	error "Bad userID"
+//	@Failure		403	{object}	error "User blocked"
 //	@Failure		404	{object}	error "User not found"
 //	@Security		ApiKeyAuth
 //	@Router			/users/{id} [put]
@@ -81,17 +95,40 @@ func (api *application) followUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	if err := api.store.Followers.Follow(r.Context(), followerUser.ID, followedId); err != nil {
+	requested, err := api.store.Followers.Follow(r.Context(), followerUser.ID, followedId)
+	if err != nil {
 
 		switch err {
 		case store.ErrConflict:
-			api.conflictError(w, r, errors.New("user is already being followed"))
+			api.conflictError(w, r, errors.New("user is already being followed or requested"))
+		case store.ErrNotFound:
+			api.notFoundError(w, r, err)
+		case store.ErrBlocked:
+			api.forbiddenError(w, r)
 		default:
 			api.internalServerError(w, r, err)
 		}
 		return
 	}
 
+	if requested {
+		// users with a pending request are not suggested to the follower
+		if err := api.invalidateSuggestions(r.Context(), followerUser.ID); err != nil {
+			api.internalServerError(w, r, err)
+			return
+		}
+
+		if err := api.jsonResponse(w, http.StatusAccepted, nil); err != nil {
+			api.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := api.invalidateFollows(r.Context(), followerUser.ID, followedId); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+
 	if err := api.jsonResponse(w, http.StatusNoContent, nil); err != nil {
 		api.internalServerError(w, r, err)
 	}
@@ -100,7 +137,7 @@ func (api *application) followUserHandler(w http.ResponseWriter, r *http.Request
 // UnfollowUser gdoc
 //
 //	@Summary		Unfollow a user
-//	@Description	Unfollow a user by ID
+//	@Description	Unfollow a user by ID, or withdraw the follow request
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
@@ -130,11 +167,119 @@ func (api *application) unfollowUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	if err := api.invalidateFollows(r.Context(), followerUser.ID, unfollowedId); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+
 	if err := api.jsonResponse(w, http.StatusNoContent, nil); err != nil {
 		api.internalServerError(w, r, err)
 	}
 }
 
+// invalidateFollows drops the cached users whose follows changed, along
+// with their follow suggestions.
+func (app *application) invalidateFollows(ctx context.Context, followerID, userID int64) error {
+	for _, id := range []int64{followerID, userID} {
+		if err := app.invalidateUser(ctx, id); err != nil {
+			return err
+		}
+
+		if err := app.invalidateSuggestions(ctx, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetFollowers godoc
+//
+//	@Summary		Lists the followers of a user
+//	@Description	Lists the active users following a user, most recent first
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int	true	"User ID"
+//	@Param			limit	query		int	false	"Limit"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	[]store.FollowListEntry
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error	"Private account"
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/followers [get]
+func (app *application) getFollowersHandler(w http.ResponseWriter, r *http.Request) {
+	app.listFollows(w, r, app.store.Followers.GetFollowers)
+}
+
+// GetFollowing godoc
+//
+//	@Summary		Lists the users a user follows
+//	@Description	Lists the active users a user follows, most recent first
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int	true	"User ID"
+//	@Param			limit	query		int	false	"Limit"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	[]store.FollowListEntry
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error	"Private account"
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/following [get]
+func (app *application) getFollowingHandler(w http.ResponseWriter, r *http.Request) {
+	app.listFollows(w, r, app.store.Followers.GetFollowing)
+}
+
+func (app *application) listFollows(w http.ResponseWriter, r *http.Request, list func(context.Context, int64, store.PaginatedQuery) ([]store.FollowListEntry, error)) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	pq, err := store.PaginatedQuery{Limit: 20, Offset: 0}.Parse(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(pq); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	allowed, err := app.store.Followers.CanView(ctx, getUserFromCtx(r).ID, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if !allowed {
+		app.forbiddenError(w, r)
+		return
+	}
+
+	users, err := list(ctx, userID, pq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, users); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
 // ActivateUser godoc
 //
 //	@Summary		Activates/Register a user
@@ -165,7 +310,78 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 	}
 }
 
+// RevokeUserSessions godoc
+//
+//	@Summary		Revokes all sessions of a user
+//	@Description	Invalidates every access, refresh and personal access token issued to a user. Admin only
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"Sessions revoked"
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/sessions [delete]
+func (app *application) revokeUserSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := app.revokeUserSessions(r.Context(), userID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func getUserFromCtx(r *http.Request) *store.User {
 	user := r.Context().Value(userCtx).(*store.User)
 	return user
 }
+
+// SearchUsers godoc
+//
+//	@Summary		Searches users
+//	@Description	Searches active users by username or display name. Prefix matches come first, followed by similar names
+//	@Tags			users
+//	@Produce		json
+//	@Param			q		query		string	true	"Search term"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Success		200		{object}	[]store.UserSearchResult
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/search [get]
+func (app *application) searchUsersHandler(w http.ResponseWriter, r *http.Request) {
+	term := strings.TrimSpace(r.URL.Query().Get("q"))
+	if term == "" || utf8.RuneCountInString(term) > 100 {
+		app.badRequestError(w, r, errors.New("q must have between 1 and 100 characters"))
+		return
+	}
+
+	pq, err := store.PaginatedQuery{Limit: 20, Offset: 0}.Parse(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(pq); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	users, err := app.store.Users.Search(r.Context(), getUserFromCtx(r).ID, term, pq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, users); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
 	"testing"
 
+	"github.com/alejandro-cardenas-g/social/internal/store"
 	"github.com/alejandro-cardenas-g/social/internal/store/cache"
 	"github.com/stretchr/testify/mock"
 )
@@ -15,6 +18,7 @@ func TestGetUser(t *testing.T) {
 		},
 	}
 	app := newTestApplication(t, withRedis)
+	mockAuthenticatedUser(app, &store.User{ID: mockUserID})
 	mux := app.mount()
 
 	testToken, err := app.authenticator.GenerateToken(nil)
@@ -55,6 +59,7 @@ func TestGetUser(t *testing.T) {
 		}
 
 		app := newTestApplication(t, withRedis)
+		mockAuthenticatedUser(app, &store.User{ID: mockUserID})
 		mux := app.mount()
 
 		mockCacheStore := app.cacheStorage.Users.(*cache.UsersMockStore)
@@ -97,4 +102,155 @@ func TestGetUser(t *testing.T) {
 
 		mockCacheStore.Calls = nil // Reset mock expectations
 	})
+
+	t.Run("should not disclose the account settings of the user", func(t *testing.T) {
+		app := newTestApplication(t, config{})
+		scheduledAt := "2026-01-01T00:00:00Z"
+		mockAuthenticatedUser(app, &store.User{ID: mockUserID, TwoFactorEnabled: true, DeletionScheduledAt: &scheduledAt})
+		mux := app.mount()
+
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		if strings.Contains(rr.Body.String(), "two_factor_enabled") || strings.Contains(rr.Body.String(), "deletion_scheduled_at") {
+			t.Errorf("expected the account settings to be hidden, got %s", rr.Body.String())
+		}
+	})
+}
+
+func TestGetFollowers(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mockAuthenticatedUser(app, &store.User{ID: mockUserID})
+	mux := app.mount()
+
+	get := func(t *testing.T, path string) int {
+		return authenticatedRequest(t, app, mux, http.MethodGet, path, nil).Code
+	}
+
+	t.Run("should list followers and following", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, get(t, "/v1/users/1/followers"))
+		checkResponseCode(t, http.StatusOK, get(t, "/v1/users/1/following?limit=50&offset=10"))
+	})
+
+	t.Run("should reject invalid pagination", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, get(t, "/v1/users/1/followers?limit=abc"))
+		checkResponseCode(t, http.StatusBadRequest, get(t, "/v1/users/1/followers?limit=500"))
+	})
+}
+
+func TestFollowRequests(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mockAuthenticatedUser(app, &store.User{ID: mockUserID})
+	mux := app.mount()
+
+	followers := app.store.Followers.(*store.MockFollowersStore)
+
+	request := func(t *testing.T, method, path string) int {
+		return authenticatedRequest(t, app, mux, method, path, nil).Code
+	}
+
+	t.Run("should list the pending requests", func(t *testing.T) {
+		followers.On("GetRequests", mockUserID, store.PaginatedQuery{Limit: 20}).
+			Return([]store.FollowRequest{{RequesterID: 2, Username: "ana"}}, nil).Once()
+
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodGet, "/v1/users/me/follow-requests"))
+	})
+
+	t.Run("should approve and reject requests", func(t *testing.T) {
+		followers.On("ApproveRequest", mockUserID, int64(2)).Return(nil).Once()
+		followers.On("RejectRequest", mockUserID, int64(3)).Return(nil).Once()
+
+		checkResponseCode(t, http.StatusNoContent, request(t, http.MethodPut, "/v1/users/me/follow-requests/2/approve"))
+		checkResponseCode(t, http.StatusNoContent, request(t, http.MethodPut, "/v1/users/me/follow-requests/3/reject"))
+	})
+
+	t.Run("should not find a request that is not pending", func(t *testing.T) {
+		followers.On("ApproveRequest", mockUserID, int64(4)).Return(store.ErrNotFound).Once()
+		followers.On("RejectRequest", mockUserID, int64(4)).Return(store.ErrNotFound).Once()
+
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodPut, "/v1/users/me/follow-requests/4/approve"))
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodPut, "/v1/users/me/follow-requests/4/reject"))
+	})
+
+	t.Run("should reject an invalid requester", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, request(t, http.MethodPut, "/v1/users/me/follow-requests/abc/approve"))
+	})
+
+	followers.AssertExpectations(t)
+}
+
+func TestBlockAndMute(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mockAuthenticatedUser(app, &store.User{ID: mockUserID})
+	mux := app.mount()
+
+	blocks := app.store.Blocks.(*store.MockBlocksStore)
+	mutes := app.store.Mutes.(*store.MockMutesStore)
+
+	request := func(t *testing.T, method, path string) int {
+		return authenticatedRequest(t, app, mux, method, path, nil).Code
+	}
+
+	t.Run("should block and mute users", func(t *testing.T) {
+		blocks.On("Block", mockUserID, int64(2)).Return(nil).Once()
+		blocks.On("Unblock", mockUserID, int64(2)).Return(nil).Once()
+		mutes.On("Mute", mockUserID, int64(2)).Return(nil).Once()
+		mutes.On("Unmute", mockUserID, int64(2)).Return(nil).Once()
+
+		for _, action := range []string{"block", "unblock", "mute", "unmute"} {
+			checkResponseCode(t, http.StatusNoContent, request(t, http.MethodPut, "/v1/users/2/"+action))
+		}
+	})
+
+	t.Run("should not find users that do not exist", func(t *testing.T) {
+		blocks.On("Block", mockUserID, int64(3)).Return(store.ErrNotFound).Once()
+		mutes.On("Mute", mockUserID, int64(3)).Return(store.ErrNotFound).Once()
+
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodPut, "/v1/users/3/block"))
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodPut, "/v1/users/3/mute"))
+	})
+
+	t.Run("should not block or mute the authenticated user", func(t *testing.T) {
+		self := fmt.Sprintf("/v1/users/%d/", mockUserID)
+
+		checkResponseCode(t, http.StatusConflict, request(t, http.MethodPut, self+"block"))
+		checkResponseCode(t, http.StatusConflict, request(t, http.MethodPut, self+"mute"))
+	})
+
+	t.Run("should list the blocked and muted users", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodGet, "/v1/users/me/blocks"))
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodGet, "/v1/users/me/mutes"))
+	})
+
+	blocks.AssertExpectations(t)
+	mutes.AssertExpectations(t)
+}
+
+func TestSearchUsers(t *testing.T) {
+	app := newTestApplication(t, config{})
+	users := mockAuthenticatedUser(app, &store.User{ID: mockUserID})
+	mux := app.mount()
+
+	get := func(t *testing.T, path string) int {
+		return authenticatedRequest(t, app, mux, http.MethodGet, path, nil).Code
+	}
+
+	t.Run("should search users", func(t *testing.T) {
+		users.On("Search", mockUserID, "gopher", mock.Anything).Return([]store.UserSearchResult{}, nil).Once()
+
+		checkResponseCode(t, http.StatusOK, get(t, "/v1/users/search?q=gopher&limit=10"))
+	})
+
+	t.Run("should require a search term", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, get(t, "/v1/users/search"))
+		checkResponseCode(t, http.StatusBadRequest, get(t, "/v1/users/search?q=%20"))
+	})
+
+	users.AssertExpectations(t)
 }
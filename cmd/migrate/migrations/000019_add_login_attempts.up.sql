@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS login_attempts (
+    key varchar(320) PRIMARY KEY,
+    failures int NOT NULL DEFAULT 0,
+    window_start timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    locked_until timestamp(0) with time zone
+);
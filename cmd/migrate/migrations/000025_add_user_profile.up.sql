@@ -0,0 +1,13 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS display_name varchar(100) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS bio varchar(500) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS avatar_url varchar(2048) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS location varchar(100) NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS email_changes (
+    token bytea PRIMARY KEY,
+    user_id bigint NOT NULL,
+    new_email citext NOT NULL,
+    expiry timestamp(0) with time zone NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
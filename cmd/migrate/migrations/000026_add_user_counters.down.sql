@@ -0,0 +1,13 @@
+DROP INDEX IF EXISTS idx_followers_follower_id_created_at;
+DROP INDEX IF EXISTS idx_followers_user_id_created_at;
+
+DROP TRIGGER IF EXISTS posts_count ON posts;
+DROP TRIGGER IF EXISTS followers_counts ON followers;
+
+DROP FUNCTION IF EXISTS update_posts_count();
+DROP FUNCTION IF EXISTS update_follow_counts();
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS followers_count,
+    DROP COLUMN IF EXISTS following_count,
+    DROP COLUMN IF EXISTS posts_count;
@@ -0,0 +1,47 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS followers_count bigint NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS following_count bigint NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS posts_count bigint NOT NULL DEFAULT 0;
+
+UPDATE users u SET
+    followers_count = (SELECT COUNT(*) FROM followers f WHERE f.user_id = u.id),
+    following_count = (SELECT COUNT(*) FROM followers f WHERE f.follower_id = u.id),
+    posts_count = (SELECT COUNT(*) FROM posts p WHERE p.user_id = u.id);
+
+-- the counters are kept in the same transaction as the rows they count
+CREATE OR REPLACE FUNCTION update_follow_counts() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE users SET followers_count = followers_count + 1 WHERE id = NEW.user_id;
+        UPDATE users SET following_count = following_count + 1 WHERE id = NEW.follower_id;
+        RETURN NEW;
+    END IF;
+
+    UPDATE users SET followers_count = followers_count - 1 WHERE id = OLD.user_id;
+    UPDATE users SET following_count = following_count - 1 WHERE id = OLD.follower_id;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION update_posts_count() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE users SET posts_count = posts_count + 1 WHERE id = NEW.user_id;
+        RETURN NEW;
+    END IF;
+
+    UPDATE users SET posts_count = posts_count - 1 WHERE id = OLD.user_id;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER followers_counts
+    AFTER INSERT OR DELETE ON followers
+    FOR EACH ROW EXECUTE FUNCTION update_follow_counts();
+
+CREATE TRIGGER posts_count
+    AFTER INSERT OR DELETE ON posts
+    FOR EACH ROW EXECUTE FUNCTION update_posts_count();
+
+CREATE INDEX IF NOT EXISTS idx_followers_user_id_created_at ON followers (user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_followers_follower_id_created_at ON followers (follower_id, created_at);
@@ -0,0 +1,2 @@
+-- popular authors are the fallback for follow suggestions
+CREATE INDEX IF NOT EXISTS idx_users_followers_count ON users (followers_count DESC) WHERE is_active;
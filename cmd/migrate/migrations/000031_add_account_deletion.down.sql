@@ -0,0 +1,15 @@
+DROP INDEX IF EXISTS idx_comments_user_id;
+
+ALTER TABLE user_invitations DROP CONSTRAINT IF EXISTS fk_user_invitations_user;
+
+ALTER TABLE comments
+    DROP CONSTRAINT IF EXISTS fk_comments_post,
+    DROP CONSTRAINT IF EXISTS fk_comments_user;
+
+ALTER TABLE posts
+    DROP CONSTRAINT IF EXISTS fk_user,
+    ADD CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users (id);
+
+DROP INDEX IF EXISTS idx_users_deletion_scheduled_at;
+
+ALTER TABLE users DROP COLUMN IF EXISTS deletion_scheduled_at;
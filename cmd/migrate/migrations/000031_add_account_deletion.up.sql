@@ -0,0 +1,25 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deletion_scheduled_at timestamp(0) with time zone;
+
+CREATE INDEX IF NOT EXISTS idx_users_deletion_scheduled_at ON users (deletion_scheduled_at)
+    WHERE deletion_scheduled_at IS NOT NULL;
+
+-- rows left behind by users and posts deleted before the foreign keys existed
+DELETE FROM comments c
+WHERE NOT EXISTS (SELECT 1 FROM users u WHERE u.id = c.user_id)
+    OR NOT EXISTS (SELECT 1 FROM posts p WHERE p.id = c.post_id);
+
+DELETE FROM user_invitations i
+WHERE NOT EXISTS (SELECT 1 FROM users u WHERE u.id = i.user_id);
+
+ALTER TABLE posts
+    DROP CONSTRAINT IF EXISTS fk_user,
+    ADD CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE;
+
+ALTER TABLE comments
+    ADD CONSTRAINT fk_comments_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    ADD CONSTRAINT fk_comments_post FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE;
+
+ALTER TABLE user_invitations
+    ADD CONSTRAINT fk_user_invitations_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_comments_user_id ON comments (user_id);
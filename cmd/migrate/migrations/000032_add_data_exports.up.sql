@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS data_exports (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    status varchar(16) NOT NULL DEFAULT 'pending',
+    attempts int NOT NULL DEFAULT 0,
+    -- claimed exports are hidden from other workers until then
+    claimed_until timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    archive bytea,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    completed_at timestamp(0) with time zone,
+    expiry timestamp(0) with time zone,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+-- one export in progress per user
+CREATE UNIQUE INDEX IF NOT EXISTS idx_data_exports_user_id_pending ON data_exports (user_id)
+    WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_data_exports_claimed_until ON data_exports (claimed_until)
+    WHERE status = 'pending';
@@ -0,0 +1,2 @@
+-- the last accepted TOTP time step, so a code cannot be used twice
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step BIGINT;
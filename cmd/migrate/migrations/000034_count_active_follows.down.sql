@@ -0,0 +1,22 @@
+DROP TRIGGER IF EXISTS users_delete_follows ON users;
+DROP TRIGGER IF EXISTS users_follow_counts ON users;
+DROP FUNCTION IF EXISTS delete_user_follows();
+DROP FUNCTION IF EXISTS update_follow_counts_on_activation();
+
+CREATE OR REPLACE FUNCTION update_follow_counts() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE users SET followers_count = followers_count + 1 WHERE id = NEW.user_id;
+        UPDATE users SET following_count = following_count + 1 WHERE id = NEW.follower_id;
+        RETURN NEW;
+    END IF;
+
+    UPDATE users SET followers_count = followers_count - 1 WHERE id = OLD.user_id;
+    UPDATE users SET following_count = following_count - 1 WHERE id = OLD.follower_id;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+UPDATE users u SET
+    followers_count = (SELECT COUNT(*) FROM followers f WHERE f.user_id = u.id),
+    following_count = (SELECT COUNT(*) FROM followers f WHERE f.follower_id = u.id);
@@ -0,0 +1,60 @@
+-- a follow only counts toward the counters while the user on the other side
+-- is active, as in the followers and following lists
+CREATE OR REPLACE FUNCTION update_follow_counts() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE users SET followers_count = followers_count + 1
+        WHERE id = NEW.user_id AND EXISTS (SELECT 1 FROM users WHERE id = NEW.follower_id AND is_active);
+        UPDATE users SET following_count = following_count + 1
+        WHERE id = NEW.follower_id AND EXISTS (SELECT 1 FROM users WHERE id = NEW.user_id AND is_active);
+        RETURN NEW;
+    END IF;
+
+    UPDATE users SET followers_count = followers_count - 1
+    WHERE id = OLD.user_id AND EXISTS (SELECT 1 FROM users WHERE id = OLD.follower_id AND is_active);
+    UPDATE users SET following_count = following_count - 1
+    WHERE id = OLD.follower_id AND EXISTS (SELECT 1 FROM users WHERE id = OLD.user_id AND is_active);
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+-- activating or deactivating a user adds or takes off its follows
+CREATE OR REPLACE FUNCTION update_follow_counts_on_activation() RETURNS trigger AS $$
+DECLARE
+    delta bigint := CASE WHEN NEW.is_active THEN 1 ELSE -1 END;
+BEGIN
+    UPDATE users SET followers_count = followers_count + delta
+    WHERE id IN (SELECT user_id FROM followers WHERE follower_id = NEW.id);
+    UPDATE users SET following_count = following_count + delta
+    WHERE id IN (SELECT follower_id FROM followers WHERE user_id = NEW.id);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- the follows of a deleted user are removed while it can still be seen, so
+-- update_follow_counts knows whether they were counted
+CREATE OR REPLACE FUNCTION delete_user_follows() RETURNS trigger AS $$
+BEGIN
+    DELETE FROM followers WHERE user_id = OLD.id OR follower_id = OLD.id;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER users_follow_counts
+    AFTER UPDATE OF is_active ON users
+    FOR EACH ROW WHEN (OLD.is_active IS DISTINCT FROM NEW.is_active)
+    EXECUTE FUNCTION update_follow_counts_on_activation();
+
+CREATE TRIGGER users_delete_follows
+    BEFORE DELETE ON users
+    FOR EACH ROW EXECUTE FUNCTION delete_user_follows();
+
+UPDATE users u SET
+    followers_count = (
+        SELECT COUNT(*) FROM followers f JOIN users o ON o.id = f.follower_id
+        WHERE f.user_id = u.id AND o.is_active
+    ),
+    following_count = (
+        SELECT COUNT(*) FROM followers f JOIN users o ON o.id = f.user_id
+        WHERE f.follower_id = u.id AND o.is_active
+    );
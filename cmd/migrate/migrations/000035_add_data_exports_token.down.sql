@@ -0,0 +1 @@
+ALTER TABLE data_exports DROP COLUMN IF EXISTS token;
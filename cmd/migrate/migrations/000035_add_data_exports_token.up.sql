@@ -0,0 +1,2 @@
+-- hash of the random token of the download link, export ids are sequential
+ALTER TABLE data_exports ADD COLUMN IF NOT EXISTS token bytea;
@@ -18,6 +18,6 @@ func main() {
 
 	defer conn.Close()
 
-	store := store.NewStorage(conn)
+	store := store.NewStorage(conn, store.DefaultPasswordHashing())
 	db.Seed(store, conn)
 }
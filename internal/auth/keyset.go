@@ -0,0 +1,229 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningKey is one key of a KeySetAuthenticator. Keys without a private
+// part are retired: tokens signed with them still validate until the key
+// is removed, but no new token is signed with them.
+type SigningKey struct {
+	ID      string
+	Private crypto.Signer
+	Public  crypto.PublicKey
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// KeyPublisher is implemented by authenticators whose tokens can be
+// verified by third parties with public keys.
+type KeyPublisher interface {
+	JWKS() JWKSet
+}
+
+// KeySetAuthenticator signs tokens with an asymmetric key identified by the
+// kid header, so several keys can be valid at the same time while rotating.
+type KeySetAuthenticator struct {
+	method    jwt.SigningMethod
+	keys      map[string]SigningKey
+	activeKid string
+	aud       string
+	iss       string
+}
+
+func NewKeySetAuthenticator(alg string, keys []SigningKey, activeKid, aud, iss string) (*KeySetAuthenticator, error) {
+	method := jwt.GetSigningMethod(alg)
+	if method != jwt.SigningMethodRS256 && method != jwt.SigningMethodEdDSA {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+
+	a := &KeySetAuthenticator{
+		method:    method,
+		keys:      make(map[string]SigningKey, len(keys)),
+		activeKid: activeKid,
+		aud:       aud,
+		iss:       iss,
+	}
+
+	for _, key := range keys {
+		if err := checkKeyType(method, key.Public); err != nil {
+			return nil, fmt.Errorf("key %s: %w", key.ID, err)
+		}
+		a.keys[key.ID] = key
+	}
+
+	active, ok := a.keys[activeKid]
+	if !ok {
+		return nil, fmt.Errorf("active key %q not found", activeKid)
+	}
+
+	if active.Private == nil {
+		return nil, fmt.Errorf("active key %q has no private key", activeKid)
+	}
+
+	return a, nil
+}
+
+func (a *KeySetAuthenticator) GenerateToken(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(a.method, claims)
+	token.Header["kid"] = a.activeKid
+
+	return token.SignedString(a.keys[a.activeKid].Private)
+}
+
+func (a *KeySetAuthenticator) ValidateToken(token string) (*jwt.Token, error) {
+	return jwt.Parse(token, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+
+		key, ok := a.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+
+		return key.Public, nil
+	},
+		jwt.WithExpirationRequired(),
+		jwt.WithAudience(a.aud),
+		jwt.WithIssuer(a.iss),
+		jwt.WithValidMethods([]string{a.method.Alg()}))
+}
+
+func (a *KeySetAuthenticator) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+
+	for _, key := range a.keys {
+		jwk := JWK{
+			Kid: key.ID,
+			Use: "sig",
+			Alg: a.method.Alg(),
+		}
+
+		switch pub := key.Public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+
+	return set
+}
+
+// LoadSigningKeys reads every .pem file in dir. The file name without the
+// extension is the key id. Private keys are used for signing, public keys
+// only to validate tokens of retired keys.
+func LoadSigningKeys(dir string) ([]SigningKey, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]SigningKey, 0, len(files))
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := parseSigningKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+
+		key.ID = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func parseSigningKey(data []byte) (SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return SigningKey{}, errors.New("no PEM block found")
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY", "RSA PRIVATE KEY":
+		var private any
+		var err error
+
+		if block.Type == "RSA PRIVATE KEY" {
+			private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		} else {
+			private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		}
+
+		if err != nil {
+			return SigningKey{}, err
+		}
+
+		signer, ok := private.(crypto.Signer)
+		if !ok {
+			return SigningKey{}, errors.New("unsupported private key")
+		}
+
+		return SigningKey{Private: signer, Public: signer.Public()}, nil
+	case "PUBLIC KEY":
+		public, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return SigningKey{}, err
+		}
+
+		return SigningKey{Public: public}, nil
+	default:
+		return SigningKey{}, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+}
+
+func checkKeyType(method jwt.SigningMethod, public crypto.PublicKey) error {
+	switch public.(type) {
+	case *rsa.PublicKey:
+		if method == jwt.SigningMethodRS256 {
+			return nil
+		}
+	case ed25519.PublicKey:
+		if method == jwt.SigningMethodEdDSA {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("key type %T cannot be used with %s", public, method.Alg())
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestKeySetAuthenticator(t *testing.T) {
+	_, oldKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, newKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := jwt.MapClaims{
+		"sub": 1,
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"iss": "test",
+		"aud": "test",
+	}
+
+	before, err := NewKeySetAuthenticator("EdDSA", []SigningKey{
+		{ID: "old", Private: oldKey, Public: oldKey.Public()},
+	}, "old", "test", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldToken, err := before.GenerateToken(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should validate tokens of retired keys after a rotation", func(t *testing.T) {
+		after, err := NewKeySetAuthenticator("EdDSA", []SigningKey{
+			{ID: "old", Public: oldKey.Public()},
+			{ID: "new", Private: newKey, Public: newKey.Public()},
+		}, "new", "test", "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := after.ValidateToken(oldToken); err != nil {
+			t.Errorf("expected the old token to be valid and we got %v", err)
+		}
+
+		newToken, err := after.GenerateToken(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parsed, err := after.ValidateToken(newToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if parsed.Header["kid"] != "new" {
+			t.Errorf("expected the token to be signed with the new key and we got %v", parsed.Header["kid"])
+		}
+
+		if len(after.JWKS().Keys) != 2 {
+			t.Errorf("expected both keys to be published and we got %d", len(after.JWKS().Keys))
+		}
+	})
+
+	t.Run("should reject tokens of removed keys", func(t *testing.T) {
+		after, err := NewKeySetAuthenticator("EdDSA", []SigningKey{
+			{ID: "new", Private: newKey, Public: newKey.Public()},
+		}, "new", "test", "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := after.ValidateToken(oldToken); err == nil {
+			t.Error("expected the token of a removed key to be rejected")
+		}
+	})
+}
@@ -11,6 +11,8 @@ type TestAuthenticator struct{}
 const secret = "test"
 
 var testClaims = jwt.MapClaims{
+	"jti": "test",
+	"typ": "access",
 	"sub": int64(1),
 	"exp": time.Now().Add(time.Hour).Unix(),
 	"iat": time.Now().Unix(),
@@ -23,8 +25,13 @@ func NewTestAuthenticator() *TestAuthenticator {
 	return &TestAuthenticator{}
 }
 
+// GenerateToken signs claims, or fixed claims for user 1 when they are nil.
 func (a *TestAuthenticator) GenerateToken(claims jwt.Claims) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims)
+	if claims == nil {
+		claims = testClaims
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, _ := token.SignedString([]byte(secret))
 	return tokenString, nil
 }
@@ -0,0 +1,204 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockIdP is a minimal OpenID provider: discovery, keys and a token
+// endpoint that redeems a single code bound to a PKCE challenge.
+type mockIdP struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	clientID  string
+	code      string
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idp := &mockIdP{key: key, clientID: "social"}
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/keys",
+		})
+	})
+
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(JWKSet{Keys: []JWK{{
+			Kty: "RSA",
+			Kid: "idp-1",
+			Use: "sig",
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+
+		if id, _, _ := r.BasicAuth(); id != idp.clientID ||
+			r.PostFormValue("code") != idp.code ||
+			base64.RawURLEncoding.EncodeToString(sum[:]) != idp.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idp.sign(t, idp.claims)})
+	})
+
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+
+	return idp
+}
+
+func (idp *mockIdP) sign(t *testing.T, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "idp-1"
+
+	signed, err := token.SignedString(idp.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed
+}
+
+func (idp *mockIdP) idTokenClaims(nonce string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            idp.URL,
+		"sub":            "external-42",
+		"aud":            idp.clientID,
+		"exp":            time.Now().Add(time.Minute).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          nonce,
+		"email":          "gopher@example.com",
+		"email_verified": true,
+	}
+}
+
+func TestOIDCAuthorizationCodeFlow(t *testing.T) {
+	idp := newMockIdP(t)
+	ctx := context.Background()
+
+	provider := NewOIDCProvider(OIDCConfig{
+		Issuer:       idp.URL,
+		ClientID:     idp.clientID,
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost/callback",
+	})
+
+	verifier, challenge, err := GeneratePKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, "state", "nonce", challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if q := u.Query(); q.Get("code_challenge") != challenge || q.Get("code_challenge_method") != "S256" || q.Get("state") != "state" || q.Get("nonce") != "nonce" {
+		t.Fatalf("unexpected authorization URL %s", authURL)
+	}
+
+	idp.code, idp.challenge, idp.claims = "code", challenge, idp.idTokenClaims("nonce")
+
+	if _, err := provider.Exchange(ctx, "code", "wrong-verifier"); err == nil {
+		t.Fatal("expected the exchange to fail with a wrong code verifier")
+	}
+
+	rawIDToken, err := provider.Exchange(ctx, "code", verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := provider.VerifyIDToken(ctx, rawIDToken, "nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if claims.Subject != "external-42" || claims.Email != "gopher@example.com" || !claims.EmailVerified {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+}
+
+func TestOIDCVerifyIDTokenRejects(t *testing.T) {
+	idp := newMockIdP(t)
+	ctx := context.Background()
+
+	provider := NewOIDCProvider(OIDCConfig{Issuer: idp.URL, ClientID: idp.clientID})
+
+	tests := map[string]func(c jwt.MapClaims){
+		"nonce mismatch": func(c jwt.MapClaims) { c["nonce"] = "other" },
+		"wrong audience": func(c jwt.MapClaims) { c["aud"] = "someone-else" },
+		"wrong issuer":   func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" },
+		"expired":        func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+		"missing sub":    func(c jwt.MapClaims) { delete(c, "sub") },
+	}
+
+	for name, mutate := range tests {
+		t.Run(name, func(t *testing.T) {
+			claims := idp.idTokenClaims("nonce")
+			mutate(claims)
+
+			_, err := provider.VerifyIDToken(ctx, idp.sign(t, claims), "nonce")
+			if !errors.Is(err, ErrInvalidIDToken) {
+				t.Fatalf("expected ErrInvalidIDToken, got %v", err)
+			}
+		})
+	}
+
+	t.Run("forged signature", func(t *testing.T) {
+		other, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, idp.idTokenClaims("nonce"))
+		token.Header["kid"] = "idp-1"
+
+		signed, err := token.SignedString(other)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := provider.VerifyIDToken(ctx, signed, "nonce"); !errors.Is(err, ErrInvalidIDToken) {
+			t.Fatalf("expected ErrInvalidIDToken, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxPasswordBytes is the longest password bcrypt can hash. It also applies
+// when hashing with argon2id, so that bcrypt can be configured again.
+const MaxPasswordBytes = 72
+
+var ErrWeakPassword = errors.New("password does not meet the policy")
+
+// PasswordPolicy are the rules new passwords must follow. Lengths are
+// counted in characters.
+type PasswordPolicy struct {
+	MinLength        int
+	MaxLength        int
+	RequireMixedCase bool
+	RequireDigit     bool
+	RequireSymbol    bool
+}
+
+// Validate returns an error wrapping ErrWeakPassword that describes the
+// first rule the password breaks.
+func (p PasswordPolicy) Validate(password string) error {
+	length := utf8.RuneCountInString(password)
+
+	if length < p.MinLength {
+		return fmt.Errorf("%w: it must have at least %d characters", ErrWeakPassword, p.MinLength)
+	}
+
+	if (p.MaxLength > 0 && length > p.MaxLength) || len(password) > MaxPasswordBytes {
+		return fmt.Errorf("%w: it is too long", ErrWeakPassword)
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+
+	if p.RequireMixedCase && !(upper && lower) {
+		return fmt.Errorf("%w: it must mix upper and lower case letters", ErrWeakPassword)
+	}
+
+	if p.RequireDigit && !digit {
+		return fmt.Errorf("%w: it must contain a digit", ErrWeakPassword)
+	}
+
+	if p.RequireSymbol && !symbol {
+		return fmt.Errorf("%w: it must contain a symbol", ErrWeakPassword)
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	policy := PasswordPolicy{
+		MinLength:        8,
+		MaxLength:        64,
+		RequireMixedCase: true,
+		RequireDigit:     true,
+	}
+
+	cases := []struct {
+		password string
+		valid    bool
+	}{
+		{"Short1", false},
+		{"alllowercase1", false},
+		{"NoDigitsHere", false},
+		{"correct Horse 9 battery", true},
+		{"Contraseña segura 1", true},
+		{strings.Repeat("Aa1", 22), false},
+		// within MaxLength characters but over the bcrypt limit in bytes
+		{"Aa1" + strings.Repeat("ñ", 40), false},
+	}
+
+	for _, c := range cases {
+		err := policy.Validate(c.password)
+		if c.valid && err != nil {
+			t.Errorf("expected %q to be accepted, got %v", c.password, err)
+		}
+		if !c.valid && !errors.Is(err, ErrWeakPassword) {
+			t.Errorf("expected %q to be rejected, got %v", c.password, err)
+		}
+	}
+}
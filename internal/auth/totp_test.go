@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+func TestGenerateTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B SHA1 vectors, truncated to six digits
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+	cases := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, c := range cases {
+		code, err := GenerateTOTPCode(secret, time.Unix(c.unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if code != c.code {
+			t.Errorf("at %d expected code %s and we got %s", c.unix, c.code, code)
+		}
+	}
+}
+
+func TestMatchTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	step := now.Unix() / int64(TOTPPeriod.Seconds())
+
+	t.Run("should accept codes within the allowed skew", func(t *testing.T) {
+		previous, _ := GenerateTOTPCode(secret, now.Add(-TOTPPeriod))
+		matched, ok := MatchTOTP(secret, previous, now)
+		if !ok {
+			t.Fatal("expected the previous code to be accepted")
+		}
+
+		if matched != step-1 {
+			t.Errorf("expected the code to match step %d and it matched %d", step-1, matched)
+		}
+	})
+
+	t.Run("should reject codes outside the allowed skew", func(t *testing.T) {
+		old, _ := GenerateTOTPCode(secret, now.Add(-3*TOTPPeriod))
+		if _, ok := MatchTOTP(secret, old, now); ok {
+			t.Error("expected an old code to be rejected")
+		}
+	})
+}
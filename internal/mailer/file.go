@@ -0,0 +1,55 @@
+package mailer
+
+import (
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileMailer writes every rendered message as an .eml file to a directory
+// instead of delivering it. It is meant for local development and tests.
+type FileMailer struct {
+	fromEmail string
+	dir       string
+}
+
+func NewFileMailer(dir, fromEmail string) *FileMailer {
+	return &FileMailer{
+		fromEmail: fromEmail,
+		dir:       dir,
+	}
+}
+
+func (m *FileMailer) Send(templateFile, username, email string, data any, isSandbox bool) (int, error) {
+	subject, html, text, err := renderTemplate(templateFile, data)
+	if err != nil {
+		return -1, err
+	}
+
+	from := &mail.Address{Name: FromName, Address: m.fromEmail}
+	to := &mail.Address{Name: username, Address: email}
+
+	msg, err := buildMessage(from, to, subject, html, text)
+	if err != nil {
+		return -1, err
+	}
+
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return -1, err
+	}
+
+	name := fmt.Sprintf("%s_%s_%s.eml",
+		time.Now().UTC().Format("20060102T150405.000000000"),
+		strings.TrimSuffix(templateFile, filepath.Ext(templateFile)),
+		strings.NewReplacer("@", "_at_", "/", "_", `\`, "_").Replace(email),
+	)
+
+	if err := os.WriteFile(filepath.Join(m.dir, name), msg, 0o644); err != nil {
+		return -1, err
+	}
+
+	return 0, nil
+}
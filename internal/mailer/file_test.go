@@ -0,0 +1,100 @@
+package mailer
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	m := NewFileMailer(dir, "noreply@example.com")
+
+	vars := map[string]any{
+		"Username":      "gopher",
+		"ActivationURL": "http://localhost/confirm/abc",
+	}
+
+	if _, err := m.Send(UserWelcomeTemplate, "Evil\r\nBcc: x@example.com", "gopher@example.com", vars, true); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(files))
+	}
+
+	f, err := os.Open(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	msg, err := mail.ReadMessage(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := msg.Header.Get("Bcc"); got != "" {
+		t.Errorf("header injected through the recipient name: %q", got)
+	}
+
+	to, err := msg.Header.AddressList("To")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(to) != 1 || to[0].Address != "gopher@example.com" {
+		t.Errorf("unexpected recipients %v", to)
+	}
+
+	if !strings.Contains(msg.Header.Get("Subject"), "Finish Registration") {
+		t.Errorf("unexpected subject %q", msg.Header.Get("Subject"))
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mediaType != "multipart/alternative" {
+		t.Fatalf("unexpected content type %q", mediaType)
+	}
+
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+
+	var types []string
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(string(body), "http://localhost/confirm/abc") {
+			t.Errorf("%s part misses the activation URL", part.Header.Get("Content-Type"))
+		}
+
+		types = append(types, strings.SplitN(part.Header.Get("Content-Type"), ";", 2)[0])
+	}
+
+	if strings.Join(types, ",") != "text/plain,text/html" {
+		t.Errorf("unexpected parts %v", types)
+	}
+}
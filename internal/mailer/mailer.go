@@ -3,9 +3,17 @@ package mailer
 import "embed"
 
 const (
-	FromName            = "GoSocialPosts"
-	MaxRetries          = 3
-	UserWelcomeTemplate = "user_invitation.templ"
+	FromName                  = "GoSocialPosts"
+	MaxRetries                = 3
+	UserWelcomeTemplate       = "user_invitation.templ"
+	PasswordResetTemplate     = "password_reset.templ"
+	AccountLockedTemplate     = "account_locked.templ"
+	MagicLinkTemplate         = "magic_link.templ"
+	EmailChangeTemplate       = "email_change.templ"
+	EmailChangeNoticeTemplate = "email_change_notice.templ"
+	PasswordChangedTemplate   = "password_changed.templ"
+	AccountDeletionTemplate   = "account_deletion.templ"
+	DataExportTemplate        = "data_export.templ"
 )
 
 //go:embed "templates"
@@ -0,0 +1,60 @@
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"time"
+)
+
+// buildMessage renders an RFC 5322 multipart/alternative message with a
+// plain-text and an HTML part. Names and the subject are encoded so they
+// cannot inject headers.
+func buildMessage(from, to *mail.Address, subject, html, text string) ([]byte, error) {
+	msg := new(bytes.Buffer)
+	parts := multipart.NewWriter(msg)
+
+	headers := [][2]string{
+		{"From", from.String()},
+		{"To", to.String()},
+		{"Subject", mime.QEncoding.Encode("UTF-8", subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", fmt.Sprintf(`multipart/alternative; boundary="%s"`, parts.Boundary())},
+	}
+
+	for _, h := range headers {
+		fmt.Fprintf(msg, "%s: %s\r\n", h[0], h[1])
+	}
+	msg.WriteString("\r\n")
+
+	// clients show the last part they support, so HTML goes last
+	for _, part := range [][2]string{{"text/plain", text}, {"text/html", html}} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part[0] + `; charset="UTF-8"`},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part[1])); err != nil {
+			return nil, err
+		}
+
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	return msg.Bytes(), nil
+}
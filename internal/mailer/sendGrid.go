@@ -1,8 +1,6 @@
 package mailer
 
 import (
-	"bytes"
-	"html/template"
 	"time"
 
 	"github.com/sendgrid/sendgrid-go"
@@ -28,22 +26,12 @@ func (m *SendGripMailer) Send(templateFile, username, email string, data any, is
 	from := mail.NewEmail(FromName, m.fromEmail)
 	to := mail.NewEmail(username, email)
 
-	tmpl, err := template.ParseFS(FS, "templates/"+templateFile)
+	subject, html, text, err := renderTemplate(templateFile, data)
 	if err != nil {
 		return -1, err
 	}
 
-	subject := new(bytes.Buffer)
-	if err := tmpl.ExecuteTemplate(subject, "subject", data); err != nil {
-		return -1, err
-	}
-
-	body := new(bytes.Buffer)
-	if err := tmpl.ExecuteTemplate(body, "body", data); err != nil {
-		return -1, err
-	}
-
-	message := mail.NewSingleEmail(from, subject.String(), to, "", body.String())
+	message := mail.NewSingleEmail(from, subject, to, text, html)
 
 	message.SetMailSettings(&mail.MailSettings{
 		SandboxMode: &mail.Setting{
@@ -0,0 +1,54 @@
+package mailer
+
+import (
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+)
+
+// SMTPMailer delivers through a plain SMTP server, such as MailHog in
+// local development.
+type SMTPMailer struct {
+	fromEmail string
+	addr      string
+	auth      smtp.Auth
+}
+
+// NewSMTP returns a mailer for host:port. Authentication is skipped when
+// username is empty.
+func NewSMTP(host string, port int, username, password, fromEmail string) *SMTPMailer {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &SMTPMailer{
+		fromEmail: fromEmail,
+		addr:      net.JoinHostPort(host, strconv.Itoa(port)),
+		auth:      auth,
+	}
+}
+
+// Send ignores isSandbox, SMTP servers have no such mode. The returned
+// status is always 0 since SMTP has no HTTP status.
+func (m *SMTPMailer) Send(templateFile, username, email string, data any, isSandbox bool) (int, error) {
+	subject, html, text, err := renderTemplate(templateFile, data)
+	if err != nil {
+		return -1, err
+	}
+
+	from := &mail.Address{Name: FromName, Address: m.fromEmail}
+	to := &mail.Address{Name: username, Address: email}
+
+	msg, err := buildMessage(from, to, subject, html, text)
+	if err != nil {
+		return -1, err
+	}
+
+	if err := smtp.SendMail(m.addr, m.auth, from.Address, []string{to.Address}, msg); err != nil {
+		return -1, err
+	}
+
+	return 0, nil
+}
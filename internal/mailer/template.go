@@ -0,0 +1,124 @@
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// DefaultLanguage is the language of the templates without a locale suffix.
+const DefaultLanguage = "en"
+
+// SupportedLanguages lists the languages the templates are translated to.
+var SupportedLanguages = []string{"en", "es"}
+
+// IsSupportedLanguage reports whether the templates are translated to
+// language.
+func IsSupportedLanguage(language string) bool {
+	return slices.Contains(SupportedLanguages, language)
+}
+
+// LocalizedTemplate returns the variant of templateFile for language, such
+// as user_invitation.es.templ, or templateFile itself when there is none.
+func LocalizedTemplate(templateFile, language string) string {
+	language = strings.ToLower(language)
+	if language == "" || language == DefaultLanguage {
+		return templateFile
+	}
+
+	ext := path.Ext(templateFile)
+	localized := strings.TrimSuffix(templateFile, ext) + "." + language + ext
+
+	if _, err := fs.Stat(FS, "templates/"+localized); err != nil {
+		return templateFile
+	}
+
+	return localized
+}
+
+// durationUnits are the singular and plural names of days, hours and minutes,
+// and the conjunction joining the last two parts, in each language.
+var durationUnits = map[string]struct {
+	day, days, hour, hours, minute, minutes, and string
+}{
+	"en": {"day", "days", "hour", "hours", "minute", "minutes", "and"},
+	"es": {"día", "días", "hora", "horas", "minuto", "minutos", "y"},
+}
+
+// FormatDuration spells out d in language for the templates, such as
+// "1 hour and 30 minutes", rounded to the minute. Languages without a
+// translation use DefaultLanguage.
+func FormatDuration(d time.Duration, language string) string {
+	units, ok := durationUnits[strings.ToLower(language)]
+	if !ok {
+		units = durationUnits[DefaultLanguage]
+	}
+
+	minutes := int64(max(d.Round(time.Minute), time.Minute) / time.Minute)
+	days, hours := minutes/(24*60), minutes/60%24
+	minutes %= 60
+
+	var parts []string
+	for _, part := range []struct {
+		n                int64
+		singular, plural string
+	}{
+		{days, units.day, units.days},
+		{hours, units.hour, units.hours},
+		{minutes, units.minute, units.minutes},
+	} {
+		switch {
+		case part.n == 1:
+			parts = append(parts, "1 "+part.singular)
+		case part.n > 1:
+			parts = append(parts, fmt.Sprintf("%d %s", part.n, part.plural))
+		}
+	}
+
+	if len(parts) == 1 {
+		return parts[0]
+	}
+
+	return strings.Join(parts[:len(parts)-1], ", ") + " " + units.and + " " + parts[len(parts)-1]
+}
+
+// renderTemplate executes the blocks of an embedded template: "subject" and
+// "text" as plain text and "body" as HTML.
+func renderTemplate(templateFile string, data any) (subject, html, text string, err error) {
+	htmlTmpl, err := htmltemplate.ParseFS(FS, "templates/"+templateFile)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	textTmpl, err := texttemplate.ParseFS(FS, "templates/"+templateFile)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	if textTmpl.Lookup("text") == nil {
+		return "", "", "", fmt.Errorf("template %s has no text block", templateFile)
+	}
+
+	subjectBuf := new(bytes.Buffer)
+	if err := textTmpl.ExecuteTemplate(subjectBuf, "subject", data); err != nil {
+		return "", "", "", err
+	}
+
+	htmlBuf := new(bytes.Buffer)
+	if err := htmlTmpl.ExecuteTemplate(htmlBuf, "body", data); err != nil {
+		return "", "", "", err
+	}
+
+	textBuf := new(bytes.Buffer)
+	if err := textTmpl.ExecuteTemplate(textBuf, "text", data); err != nil {
+		return "", "", "", err
+	}
+
+	return strings.TrimSpace(subjectBuf.String()), htmlBuf.String(), strings.TrimSpace(textBuf.String()) + "\n", nil
+}
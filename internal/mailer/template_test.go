@@ -0,0 +1,98 @@
+package mailer
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLocalizedTemplate(t *testing.T) {
+	tests := []struct {
+		language string
+		want     string
+	}{
+		{"", UserWelcomeTemplate},
+		{"en", UserWelcomeTemplate},
+		{"es", "user_invitation.es.templ"},
+		{"ES", "user_invitation.es.templ"},
+		{"fr", UserWelcomeTemplate},
+	}
+
+	for _, tt := range tests {
+		if got := LocalizedTemplate(UserWelcomeTemplate, tt.language); got != tt.want {
+			t.Errorf("LocalizedTemplate(%q) = %q, want %q", tt.language, got, tt.want)
+		}
+	}
+}
+
+func TestIsSupportedLanguage(t *testing.T) {
+	for _, language := range SupportedLanguages {
+		if !IsSupportedLanguage(language) {
+			t.Errorf("expected %q to be supported", language)
+		}
+	}
+
+	for _, language := range []string{"", "fr", "ES"} {
+		if IsSupportedLanguage(language) {
+			t.Errorf("expected %q not to be supported", language)
+		}
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d        time.Duration
+		language string
+		want     string
+	}{
+		{15 * time.Minute, "en", "15 minutes"},
+		{time.Hour, "en", "1 hour"},
+		{90 * time.Minute, "en", "1 hour and 30 minutes"},
+		{24 * time.Hour, "en", "1 day"},
+		{49*time.Hour + time.Minute, "en", "2 days, 1 hour and 1 minute"},
+		{20 * time.Second, "en", "1 minute"},
+		{72 * time.Hour, "es", "3 días"},
+		{90 * time.Minute, "es", "1 hora y 30 minutos"},
+		{time.Minute, "fr", "1 minute"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatDuration(tt.d, tt.language); got != tt.want {
+			t.Errorf("FormatDuration(%s, %q) = %q, want %q", tt.d, tt.language, got, tt.want)
+		}
+	}
+}
+
+func TestTemplatesRenderEveryVariant(t *testing.T) {
+	files, err := fs.Glob(FS, "templates/*.templ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vars := map[string]any{
+		"Username":      "gopher",
+		"ActivationURL": "http://localhost/confirm/abc?x=1&y=2",
+		"ResetURL":      "http://localhost/reset-password/abc?x=1&y=2",
+		"ExpiresIn":     "1 hour",
+		"LockedFor":     "15 minutes",
+	}
+
+	for _, file := range files {
+		name := strings.TrimPrefix(file, "templates/")
+
+		subject, html, text, err := renderTemplate(name, vars)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+
+		if subject == "" || strings.TrimSpace(html) == "" || strings.TrimSpace(text) == "" {
+			t.Errorf("%s: empty subject, html or text", name)
+		}
+
+		if strings.Contains(text, "&amp;") || strings.Contains(text, "<p>") {
+			t.Errorf("%s: text part contains HTML", name)
+		}
+	}
+}
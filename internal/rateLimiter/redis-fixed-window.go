@@ -0,0 +1,55 @@
+package ratelimiter
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// allowScript counts a request in the window of KEYS[1] and returns the
+// count and the milliseconds left in the window. ARGV[1] is the window in
+// milliseconds.
+var allowScript = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if count == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return {count, redis.call("PTTL", KEYS[1])}
+`)
+
+// RedisFixedWindowRateLimiter shares its counters between every instance of
+// the API. Redis expires the windows, so nothing is kept in memory.
+type RedisFixedWindowRateLimiter struct {
+	rdb    *redis.Client
+	prefix string
+	limit  int
+	window time.Duration
+}
+
+func NewRedisFixedWindowRateLimiter(rdb *redis.Client, prefix string, limit int, window time.Duration) *RedisFixedWindowRateLimiter {
+	return &RedisFixedWindowRateLimiter{
+		rdb:    rdb,
+		prefix: prefix,
+		limit:  limit,
+		window: window,
+	}
+}
+
+// Allow lets the request through when Redis cannot be reached, so an outage
+// does not block the endpoints it protects.
+func (rl *RedisFixedWindowRateLimiter) Allow(key string) (bool, time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	res, err := allowScript.Run(ctx, rl.rdb, []string{rl.prefix + key}, rl.window.Milliseconds()).Int64Slice()
+	if err != nil || len(res) != 2 {
+		return true, 0
+	}
+
+	if res[0] <= int64(rl.limit) {
+		return true, 0
+	}
+
+	return false, time.Duration(res[1]) * time.Millisecond
+}
@@ -0,0 +1,147 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type AccessToken struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"user_id"`
+	Name       string     `json:"name"`
+	Token      string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	Expiry     time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  string     `json:"created_at"`
+}
+
+type AccessTokensStore struct {
+	db *sql.DB
+}
+
+func (s *AccessTokensStore) Create(ctx context.Context, token *AccessToken) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+		INSERT INTO personal_access_tokens (user_id, name, token, scopes, expiry)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at
+	`
+
+	return s.db.QueryRowContext(ctx, query, token.UserID, token.Name, token.Token, pq.Array(token.Scopes), token.Expiry).Scan(
+		&token.ID,
+		&token.CreatedAt,
+	)
+}
+
+func (s *AccessTokensStore) GetByUserID(ctx context.Context, userID int64) ([]AccessToken, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+		SELECT id, user_id, name, scopes, expiry, last_used_at, created_at
+		FROM personal_access_tokens
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	tokens := []AccessToken{}
+
+	for rows.Next() {
+		t := AccessToken{}
+		if err := rows.Scan(
+			&t.ID,
+			&t.UserID,
+			&t.Name,
+			pq.Array(&t.Scopes),
+			&t.Expiry,
+			&t.LastUsedAt,
+			&t.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+
+	return tokens, rows.Err()
+}
+
+// Authenticate looks up an unexpired token by its hash and records its use.
+func (s *AccessTokensStore) Authenticate(ctx context.Context, token string) (*AccessToken, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+		UPDATE personal_access_tokens SET last_used_at = NOW()
+		WHERE token = $1 AND expiry > NOW()
+		RETURNING id, user_id, name, scopes, expiry, last_used_at, created_at
+	`
+
+	t := &AccessToken{}
+
+	err := s.db.QueryRowContext(ctx, query, token).Scan(
+		&t.ID,
+		&t.UserID,
+		&t.Name,
+		pq.Array(&t.Scopes),
+		&t.Expiry,
+		&t.LastUsedAt,
+		&t.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+func (s *AccessTokensStore) Delete(ctx context.Context, tokenID int64, userID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `DELETE FROM personal_access_tokens WHERE id = $1 AND user_id = $2`
+
+	res, err := s.db.ExecContext(ctx, query, tokenID, userID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// DeleteByUser revokes every personal access token of the user.
+func (s *AccessTokensStore) DeleteByUser(ctx context.Context, userID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `DELETE FROM personal_access_tokens WHERE user_id = $1`
+
+	_, err := s.db.ExecContext(ctx, query, userID)
+	return err
+}
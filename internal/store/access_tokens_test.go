@@ -0,0 +1,49 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestAuthenticateAccessToken(t *testing.T) {
+	ctx := context.Background()
+	columns := []string{"id", "user_id", "name", "scopes", "expiry", "last_used_at", "created_at"}
+
+	t.Run("should record when an unexpired token is used", func(t *testing.T) {
+		storage, mock := newTestDB(t)
+		now := time.Now()
+
+		mock.ExpectQuery(`UPDATE personal_access_tokens SET last_used_at = NOW\(\)\s+WHERE token = \$1 AND expiry > NOW\(\)`).
+			WithArgs("hash").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(1, 7, "ci", "{users:read}", now.Add(time.Hour), now, "2026-01-01"))
+
+		token, err := storage.AccessTokens.Authenticate(ctx, "hash")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if token.UserID != 7 || len(token.Scopes) != 1 || token.Scopes[0] != "users:read" {
+			t.Errorf("expected the users:read token of user 7, got %+v", token)
+		}
+
+		if token.LastUsedAt == nil || !token.LastUsedAt.Equal(now) {
+			t.Errorf("expected the token to be last used at %v, got %v", now, token.LastUsedAt)
+		}
+	})
+
+	t.Run("should not find expired or unknown tokens", func(t *testing.T) {
+		storage, mock := newTestDB(t)
+
+		mock.ExpectQuery(`UPDATE personal_access_tokens`).
+			WithArgs("hash").
+			WillReturnRows(sqlmock.NewRows(columns))
+
+		if _, err := storage.AccessTokens.Authenticate(ctx, "hash"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+}
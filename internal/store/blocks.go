@@ -0,0 +1,146 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// ErrBlocked is returned for interactions between users when one of them
+// blocked the other.
+var ErrBlocked = errors.New("the user is blocked")
+
+type BlocksStore struct {
+	db *sql.DB
+}
+
+// RelatedUser is a user in a blocked or muted list.
+type RelatedUser struct {
+	ID          int64  `json:"id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	AvatarURL   string `json:"avatar_url"`
+	Since       string `json:"since"`
+}
+
+// Block makes blockerID block blockedID and removes the follows and follow
+// requests between them, in both directions.
+func (s *BlocksStore) Block(ctx context.Context, blockerID int64, blockedID int64) error {
+	return withTransaction(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `INSERT INTO user_blocks (blocker_id, blocked_id) VALUES ($1, $2)`
+
+		if _, err := tx.ExecContext(ctx, query, blockerID, blockedID); err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return ErrConflict
+			}
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		query = `
+			DELETE FROM followers
+			WHERE (follower_id = $1 AND user_id = $2) OR (follower_id = $2 AND user_id = $1)
+		`
+
+		if _, err := tx.ExecContext(ctx, query, blockerID, blockedID); err != nil {
+			return err
+		}
+
+		query = `
+			DELETE FROM follow_requests
+			WHERE (requester_id = $1 AND user_id = $2) OR (requester_id = $2 AND user_id = $1)
+		`
+
+		_, err := tx.ExecContext(ctx, query, blockerID, blockedID)
+		return err
+	})
+}
+
+func (s *BlocksStore) Unblock(ctx context.Context, blockerID int64, blockedID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `DELETE FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2`
+
+	res, err := s.db.ExecContext(ctx, query, blockerID, blockedID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// ExistsBetween reports whether either user blocked the other.
+func (s *BlocksStore) ExistsBetween(ctx context.Context, userID int64, otherID int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return blockExists(ctx, s.db, userID, otherID)
+}
+
+func blockExists(ctx context.Context, db queryRower, userID int64, otherID int64) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM user_blocks
+			WHERE (blocker_id = $1 AND blocked_id = $2) OR (blocker_id = $2 AND blocked_id = $1)
+		)
+	`
+
+	var blocked bool
+	err := db.QueryRowContext(ctx, query, userID, otherID).Scan(&blocked)
+	return blocked, err
+}
+
+// GetBlocked lists the users blockerID blocked, most recent first.
+func (s *BlocksStore) GetBlocked(ctx context.Context, blockerID int64, pq PaginatedQuery) ([]RelatedUser, error) {
+	query := `
+		SELECT u.id, u.username, u.display_name, u.avatar_url, b.created_at
+		FROM user_blocks b
+		INNER JOIN users u ON u.id = b.blocked_id
+		WHERE b.blocker_id = $1
+		ORDER BY b.created_at DESC, u.id DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	return listRelatedUsers(ctx, s.db, query, blockerID, pq)
+}
+
+func listRelatedUsers(ctx context.Context, db *sql.DB, query string, userID int64, pq PaginatedQuery) ([]RelatedUser, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, userID, pq.Limit, pq.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	users := []RelatedUser{}
+
+	for rows.Next() {
+		var u RelatedUser
+		if err := rows.Scan(&u.ID, &u.Username, &u.DisplayName, &u.AvatarURL, &u.Since); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type LoginAttemptsStore struct {
+	rdb *redis.Client
+}
+
+// LockedFor returns how long the key remains locked, 0 if it is not.
+func (s *LoginAttemptsStore) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := s.rdb.TTL(ctx, fmt.Sprintf("login:locked:%s", key)).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	// negative values mean the key does not exist or has no expiry
+	if ttl < 0 {
+		return 0, nil
+	}
+
+	return ttl, nil
+}
+
+// registerFailureScript counts a failure and locks the key at the limit in
+// one step, so the counter cannot be left without an expiry or be raced past
+// the limit. KEYS are the failures and locked keys, ARGV the limit, window
+// and lockout in milliseconds.
+var registerFailureScript = redis.NewScript(`
+local failures = redis.call("INCR", KEYS[1])
+if failures == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+
+if failures < tonumber(ARGV[1]) then
+	return 0
+end
+
+redis.call("DEL", KEYS[1])
+redis.call("SET", KEYS[2], 1, "PX", ARGV[3])
+return 1
+`)
+
+// RegisterFailure counts a failed attempt in the current window and locks
+// the key once maxAttempts is reached. It reports whether this failure
+// locked the key.
+func (s *LoginAttemptsStore) RegisterFailure(ctx context.Context, key string, maxAttempts int, window, lockout time.Duration) (bool, error) {
+	keys := []string{
+		fmt.Sprintf("login:failures:%s", key),
+		fmt.Sprintf("login:locked:%s", key),
+	}
+
+	locked, err := registerFailureScript.Run(ctx, s.rdb, keys, maxAttempts, window.Milliseconds(), lockout.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+
+	return locked == 1, nil
+}
+
+func (s *LoginAttemptsStore) Reset(ctx context.Context, key string) error {
+	return s.rdb.Del(ctx, fmt.Sprintf("login:failures:%s", key), fmt.Sprintf("login:locked:%s", key)).Err()
+}
@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"time"
 
 	"github.com/alejandro-cardenas-g/social/internal/store"
 	"github.com/stretchr/testify/mock"
@@ -9,7 +10,9 @@ import (
 
 func NewMockStorage() Storage {
 	return Storage{
-		Users: &UsersMockStore{},
+		Users:         &UsersMockStore{},
+		RevokedTokens: &RevokedTokensMockStore{},
+		Suggestions:   &SuggestionsMockStore{},
 	}
 }
 
@@ -25,3 +28,39 @@ func (s *UsersMockStore) Set(ctx context.Context, user *store.User) error {
 	args := s.Called(user)
 	return args.Error(0)
 }
+func (s *UsersMockStore) Delete(ctx context.Context, userID int64) error {
+	args := s.Called(userID)
+	return args.Error(0)
+}
+
+type RevokedTokensMockStore struct{}
+
+func (s *RevokedTokensMockStore) Revoke(ctx context.Context, jti string, exp time.Time) error {
+	return nil
+}
+func (s *RevokedTokensMockStore) RevokeUser(ctx context.Context, userID int64, exp time.Time) error {
+	return nil
+}
+func (s *RevokedTokensMockStore) RevokeSession(ctx context.Context, sessionID string, exp time.Time) error {
+	return nil
+}
+func (s *RevokedTokensMockStore) IsRevoked(ctx context.Context, jti string, sessionID string, userID int64, issuedAt time.Time) (bool, error) {
+	return false, nil
+}
+
+type SuggestionsMockStore struct {
+	mock.Mock
+}
+
+func (s *SuggestionsMockStore) Get(ctx context.Context, userID int64) ([]store.Suggestion, error) {
+	args := s.Called(userID)
+	return nil, args.Error(1)
+}
+func (s *SuggestionsMockStore) Set(ctx context.Context, userID int64, suggestions []store.Suggestion) error {
+	args := s.Called(userID, suggestions)
+	return args.Error(0)
+}
+func (s *SuggestionsMockStore) Delete(ctx context.Context, userID int64) error {
+	args := s.Called(userID)
+	return args.Error(0)
+}
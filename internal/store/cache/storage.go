@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"time"
 
 	"github.com/alejandro-cardenas-g/social/internal/store"
 	"github.com/go-redis/redis/v8"
@@ -11,11 +12,31 @@ type Storage struct {
 	Users interface {
 		Get(ctx context.Context, userID int64) (*store.User, error)
 		Set(ctx context.Context, user *store.User) error
+		Delete(ctx context.Context, userID int64) error
+	}
+	RevokedTokens interface {
+		Revoke(ctx context.Context, jti string, exp time.Time) error
+		RevokeUser(ctx context.Context, userID int64, exp time.Time) error
+		RevokeSession(ctx context.Context, sessionID string, exp time.Time) error
+		IsRevoked(ctx context.Context, jti string, sessionID string, userID int64, issuedAt time.Time) (bool, error)
+	}
+	LoginAttempts interface {
+		LockedFor(ctx context.Context, key string) (time.Duration, error)
+		RegisterFailure(ctx context.Context, key string, maxAttempts int, window, lockout time.Duration) (bool, error)
+		Reset(ctx context.Context, key string) error
+	}
+	Suggestions interface {
+		Get(ctx context.Context, userID int64) ([]store.Suggestion, error)
+		Set(ctx context.Context, userID int64, suggestions []store.Suggestion) error
+		Delete(ctx context.Context, userID int64) error
 	}
 }
 
 func NewRedisStorage(rdb *redis.Client) Storage {
 	return Storage{
-		Users: &UsersStore{rdb: rdb},
+		Users:         &UsersStore{rdb: rdb},
+		RevokedTokens: &RevokedTokensStore{rdb: rdb},
+		LoginAttempts: &LoginAttemptsStore{rdb: rdb},
+		Suggestions:   &SuggestionsStore{rdb: rdb},
 	}
 }
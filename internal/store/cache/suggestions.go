@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/alejandro-cardenas-g/social/internal/store"
+	"github.com/go-redis/redis/v8"
+)
+
+type SuggestionsStore struct {
+	rdb *redis.Client
+}
+
+const SuggestionsExpTime = time.Minute * 30
+
+// Get returns nil when the user's suggestions are not cached.
+func (s *SuggestionsStore) Get(ctx context.Context, userID int64) ([]store.Suggestion, error) {
+	data, err := s.rdb.Get(ctx, fmt.Sprintf("suggestions:%v", userID)).Result()
+
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	suggestions := []store.Suggestion{}
+	if err := json.Unmarshal([]byte(data), &suggestions); err != nil {
+		return nil, err
+	}
+
+	return suggestions, nil
+}
+
+func (s *SuggestionsStore) Set(ctx context.Context, userID int64, suggestions []store.Suggestion) error {
+	data, err := json.Marshal(suggestions)
+	if err != nil {
+		return err
+	}
+
+	return s.rdb.SetEX(ctx, fmt.Sprintf("suggestions:%v", userID), data, SuggestionsExpTime).Err()
+}
+
+func (s *SuggestionsStore) Delete(ctx context.Context, userID int64) error {
+	return s.rdb.Del(ctx, fmt.Sprintf("suggestions:%v", userID)).Err()
+}
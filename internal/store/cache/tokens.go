@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type RevokedTokensStore struct {
+	rdb *redis.Client
+}
+
+func (s *RevokedTokensStore) Revoke(ctx context.Context, jti string, exp time.Time) error {
+	ttl := time.Until(exp)
+	if ttl <= 0 {
+		return nil
+	}
+
+	return s.rdb.SetEX(ctx, fmt.Sprintf("revoked:jti:%s", jti), 1, ttl).Err()
+}
+
+// RevokeUser invalidates every token issued to the user up to now. The
+// entry is kept until exp, when all of those tokens are expired anyway.
+func (s *RevokedTokensStore) RevokeUser(ctx context.Context, userID int64, exp time.Time) error {
+	ttl := time.Until(exp)
+	if ttl <= 0 {
+		return nil
+	}
+
+	cacheKey := fmt.Sprintf("revoked:user:%v", userID)
+	return s.rdb.SetEX(ctx, cacheKey, time.Now().Unix(), ttl).Err()
+}
+
+func (s *RevokedTokensStore) RevokeSession(ctx context.Context, sessionID string, exp time.Time) error {
+	ttl := time.Until(exp)
+	if ttl <= 0 {
+		return nil
+	}
+
+	return s.rdb.SetEX(ctx, fmt.Sprintf("revoked:session:%s", sessionID), 1, ttl).Err()
+}
+
+// IsRevoked only rejects tokens issued in an earlier second than a user
+// revocation, see store.RevokedTokensStore.IsRevoked.
+func (s *RevokedTokensStore) IsRevoked(ctx context.Context, jti string, sessionID string, userID int64, issuedAt time.Time) (bool, error) {
+	values, err := s.rdb.MGet(ctx,
+		fmt.Sprintf("revoked:jti:%s", jti),
+		fmt.Sprintf("revoked:user:%v", userID),
+		fmt.Sprintf("revoked:session:%s", sessionID),
+	).Result()
+	if err != nil {
+		return false, err
+	}
+
+	if values[0] != nil || values[2] != nil {
+		return true, nil
+	}
+
+	if values[1] == nil {
+		return false, nil
+	}
+
+	revokedAt, err := strconv.ParseInt(fmt.Sprint(values[1]), 10, 64)
+	if err != nil {
+		return false, err
+	}
+
+	return issuedAt.Unix() < revokedAt, nil
+}
@@ -45,3 +45,8 @@ func (s *UsersStore) Set(ctx context.Context, user *store.User) error {
 
 	return s.rdb.SetEX(ctx, cacheKey, userData, UserExpTime).Err()
 }
+
+func (s *UsersStore) Delete(ctx context.Context, userID int64) error {
+	cacheKey := fmt.Sprintf("user:%v", userID)
+	return s.rdb.Del(ctx, cacheKey).Err()
+}
@@ -39,7 +39,9 @@ func (s *CommentsStore) Create(ctx context.Context, comment *Comment) error {
 	return nil
 }
 
-func (s *CommentsStore) GetByPostID(ctx context.Context, postID int64) ([]Comment, error) {
+// GetByPostID returns the comments of the post, leaving out those by users
+// viewerID muted or blocked.
+func (s *CommentsStore) GetByPostID(ctx context.Context, postID int64, viewerID int64) ([]Comment, error) {
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 	query := `
@@ -49,10 +51,12 @@ func (s *CommentsStore) GetByPostID(ctx context.Context, postID int64) ([]Commen
 		FROM comments c
 		INNER JOIN users u ON c.user_id = u.id
 		WHERE c.post_id = $1
+			AND NOT EXISTS (SELECT 1 FROM user_mutes m WHERE m.muter_id = $2 AND m.muted_id = c.user_id)
+			AND NOT EXISTS (SELECT 1 FROM user_blocks b WHERE b.blocker_id = $2 AND b.blocked_id = c.user_id)
 		ORDER BY c.created_at DESC;
 	`
 
-	rows, err := s.db.QueryContext(ctx, query, postID)
+	rows, err := s.db.QueryContext(ctx, query, postID, viewerID)
 	if err != nil {
 		return nil, err
 	}
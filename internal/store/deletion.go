@@ -0,0 +1,157 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// ScheduleDeletion marks the account to be deleted at the given time and
+// queues the email telling the user how to cancel it. It returns ErrConflict
+// when the deletion was already scheduled.
+func (s *UsersStore) ScheduleDeletion(ctx context.Context, userID int64, at time.Time, email *OutboxEmail) error {
+	return withTransaction(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `
+			UPDATE users SET deletion_scheduled_at = $1
+			WHERE id = $2 AND deletion_scheduled_at IS NULL
+		`
+
+		res, err := tx.ExecContext(ctx, query, at, userID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrConflict
+		}
+
+		return enqueueEmail(ctx, tx, email)
+	})
+}
+
+// CancelDeletion keeps the account. It returns ErrNotFound when no deletion
+// is scheduled.
+func (s *UsersStore) CancelDeletion(ctx context.Context, userID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+		UPDATE users SET deletion_scheduled_at = NULL
+		WHERE id = $1 AND deletion_scheduled_at IS NOT NULL
+	`
+
+	res, err := s.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetDueDeletions returns up to limit accounts whose grace period is over.
+func (s *UsersStore) GetDueDeletions(ctx context.Context, limit int) ([]int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+		SELECT id FROM users
+		WHERE deletion_scheduled_at <= NOW()
+		ORDER BY deletion_scheduled_at
+		LIMIT $1
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	ids := []int64{}
+
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// DeleteScheduled deletes the account and all of its content if its
+// deletion is still scheduled and due. It returns ErrNotFound otherwise,
+// such as when the user cancelled it meanwhile.
+func (s *UsersStore) DeleteScheduled(ctx context.Context, userID int64) error {
+	return withTransaction(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		// the lock makes a concurrent cancellation wait for the deletion
+		query := `
+			SELECT id FROM users
+			WHERE id = $1 AND deletion_scheduled_at <= NOW()
+			FOR UPDATE
+		`
+
+		var id int64
+		if err := tx.QueryRowContext(ctx, query, userID).Scan(&id); err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		return s.purge(ctx, tx, userID)
+	})
+}
+
+// purge removes the user with their posts, the comments they wrote or got
+// on their posts, their follows, their invitations, the emails queued for
+// them and their login attempts. The remaining rows that reference the user
+// are removed by cascade.
+func (s *UsersStore) purge(ctx context.Context, tx *sql.Tx, userID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	queries := []string{
+		`DELETE FROM comments WHERE user_id = $1 OR post_id IN (SELECT id FROM posts WHERE user_id = $1)`,
+		`DELETE FROM posts WHERE user_id = $1`,
+		`DELETE FROM followers WHERE user_id = $1 OR follower_id = $1`,
+		`DELETE FROM follow_requests WHERE user_id = $1 OR requester_id = $1`,
+		`DELETE FROM user_invitations WHERE user_id = $1`,
+		`DELETE FROM email_outbox WHERE email = (SELECT email FROM users WHERE id = $1)`,
+		// the keys of accountAttemptsKey and twoFactorAttemptsKey in cmd/api
+		`DELETE FROM login_attempts
+			WHERE key IN ((SELECT 'email:' || lower(email) FROM users WHERE id = $1), '2fa:' || $1::bigint)`,
+		`DELETE FROM users WHERE id = $1`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,33 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestDeleteUser(t *testing.T) {
+	storage, mock := newTestDB(t)
+
+	mock.ExpectBegin()
+	for _, table := range []string{"comments", "posts", "followers", "follow_requests", "user_invitations"} {
+		mock.ExpectExec(`DELETE FROM ` + table + ` WHERE`).
+			WithArgs(int64(7)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectExec(`DELETE FROM email_outbox WHERE email = \(SELECT email FROM users WHERE id = \$1\)`).
+		WithArgs(int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`DELETE FROM login_attempts\s+WHERE key IN \(\(SELECT 'email:' \|\| lower\(email\) FROM users WHERE id = \$1\), '2fa:' \|\| \$1::bigint\)`).
+		WithArgs(int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM users WHERE id = \$1`).
+		WithArgs(int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := storage.Users.Delete(context.Background(), 7); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,389 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	ExportStatusPending = "pending"
+	ExportStatusReady   = "ready"
+	ExportStatusFailed  = "failed"
+)
+
+// DataExport is a copy of a user's personal data, built in the background.
+type DataExport struct {
+	ID        int64      `json:"id"`
+	UserID    int64      `json:"user_id"`
+	Status    string     `json:"status"`
+	Attempts  int        `json:"-"`
+	CreatedAt string     `json:"created_at"`
+	Expiry    *time.Time `json:"expires_at,omitempty"`
+}
+
+// UserData is everything a data export contains.
+type UserData struct {
+	Profile   *User
+	Posts     []Post
+	Comments  []Comment
+	Followers []FollowListEntry
+	Following []FollowListEntry
+	Sessions  []Session
+}
+
+type ExportsStore struct {
+	db *sql.DB
+}
+
+// Create queues an export of the user's data. It returns ErrConflict when
+// one is already in progress.
+func (s *ExportsStore) Create(ctx context.Context, userID int64) (*DataExport, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+		INSERT INTO data_exports (user_id) VALUES ($1)
+		RETURNING id, user_id, status, created_at
+	`
+
+	export := &DataExport{}
+
+	if err := s.db.QueryRowContext(ctx, query, userID).Scan(
+		&export.ID,
+		&export.UserID,
+		&export.Status,
+		&export.CreatedAt,
+	); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return nil, ErrConflict
+		}
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// ClaimPending returns up to limit pending exports and hides them from other
+// workers for the lease duration, counting the attempt.
+func (s *ExportsStore) ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]DataExport, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+		UPDATE data_exports SET claimed_until = $2, attempts = attempts + 1
+		WHERE id IN (
+			SELECT id FROM data_exports
+			WHERE status = 'pending' AND claimed_until <= NOW()
+			ORDER BY created_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, user_id, status, attempts, created_at
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, limit, time.Now().Add(lease))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	exports := []DataExport{}
+
+	for rows.Next() {
+		var e DataExport
+		if err := rows.Scan(&e.ID, &e.UserID, &e.Status, &e.Attempts, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		exports = append(exports, e)
+	}
+
+	return exports, rows.Err()
+}
+
+// Complete stores the archive of the export, available until expiry with
+// the hashed token of its download link, and queues the email with the link
+// in the same transaction. The earlier exports of the user are deleted, only
+// the latest archive is kept.
+func (s *ExportsStore) Complete(ctx context.Context, exportID int64, token string, archive []byte, expiry time.Time, email *OutboxEmail) error {
+	return withTransaction(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `
+			UPDATE data_exports
+			SET status = 'ready', token = $1, archive = $2, expiry = $3, completed_at = NOW()
+			WHERE id = $4 AND status = 'pending'
+		`
+
+		res, err := tx.ExecContext(ctx, query, token, archive, expiry, exportID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		superseded := `
+			DELETE FROM data_exports
+			WHERE user_id = (SELECT user_id FROM data_exports WHERE id = $1)
+				AND id <> $1 AND status <> 'pending'
+		`
+
+		if _, err := tx.ExecContext(ctx, superseded, exportID); err != nil {
+			return err
+		}
+
+		return enqueueEmail(ctx, tx, email)
+	})
+}
+
+// Fail gives up on the export, letting the user request a new one.
+func (s *ExportsStore) Fail(ctx context.Context, exportID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+		UPDATE data_exports SET status = 'failed', completed_at = NOW()
+		WHERE id = $1 AND status = 'pending'
+	`
+
+	_, err := s.db.ExecContext(ctx, query, exportID)
+	return err
+}
+
+// GetArchive returns the archive of a ready export that has not expired,
+// given the hashed token of its download link.
+func (s *ExportsStore) GetArchive(ctx context.Context, exportID int64, token string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+		SELECT archive FROM data_exports
+		WHERE id = $1 AND token = $2 AND status = 'ready' AND expiry > NOW()
+	`
+
+	var archive []byte
+	if err := s.db.QueryRowContext(ctx, query, exportID, token).Scan(&archive); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return archive, nil
+}
+
+// DeleteExpired removes the expired archives and the exports that failed
+// more than a day ago.
+func (s *ExportsStore) DeleteExpired(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+		DELETE FROM data_exports
+		WHERE expiry < NOW() OR (status = 'failed' AND completed_at < NOW() - INTERVAL '1 day')
+	`
+
+	res, err := s.db.ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// collectTimeout bounds all the queries of CollectUserData together.
+const collectTimeout = time.Minute
+
+// CollectUserData reads the personal data of the user from a single
+// snapshot of the database.
+func (s *ExportsStore) CollectUserData(ctx context.Context, userID int64) (*UserData, error) {
+	ctx, cancel := context.WithTimeout(ctx, collectTimeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+
+	// read only, there is nothing to commit
+	defer tx.Rollback()
+
+	data := &UserData{}
+
+	if data.Profile, err = getUserByID(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+
+	if data.Posts, err = exportPosts(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+
+	if data.Comments, err = exportComments(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+
+	followers := `
+		SELECT u.id, u.username, u.display_name, u.avatar_url, f.created_at
+		FROM followers f
+		INNER JOIN users u ON u.id = f.follower_id
+		WHERE f.user_id = $1
+		ORDER BY f.created_at
+	`
+
+	if data.Followers, err = exportFollows(ctx, tx, followers, userID); err != nil {
+		return nil, err
+	}
+
+	following := `
+		SELECT u.id, u.username, u.display_name, u.avatar_url, f.created_at
+		FROM followers f
+		INNER JOIN users u ON u.id = f.user_id
+		WHERE f.follower_id = $1
+		ORDER BY f.created_at
+	`
+
+	if data.Following, err = exportFollows(ctx, tx, following, userID); err != nil {
+		return nil, err
+	}
+
+	if data.Sessions, err = exportSessions(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func exportPosts(ctx context.Context, tx *sql.Tx, userID int64) ([]Post, error) {
+	query := `
+		SELECT id, user_id, title, content, tags, version, created_at, updated_at
+		FROM posts
+		WHERE user_id = $1
+		ORDER BY created_at
+	`
+
+	rows, err := tx.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	posts := []Post{}
+
+	for rows.Next() {
+		var p Post
+		if err := rows.Scan(
+			&p.ID,
+			&p.UserId,
+			&p.Title,
+			&p.Content,
+			pq.Array(&p.Tags),
+			&p.Version,
+			&p.CreatedAt,
+			&p.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		posts = append(posts, p)
+	}
+
+	return posts, rows.Err()
+}
+
+func exportComments(ctx context.Context, tx *sql.Tx, userID int64) ([]Comment, error) {
+	query := `
+		SELECT id, user_id, post_id, content, created_at
+		FROM comments
+		WHERE user_id = $1
+		ORDER BY created_at
+	`
+
+	rows, err := tx.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	comments := []Comment{}
+
+	for rows.Next() {
+		var c Comment
+		if err := rows.Scan(&c.ID, &c.UserID, &c.PostID, &c.Content, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+
+	return comments, rows.Err()
+}
+
+func exportFollows(ctx context.Context, tx *sql.Tx, query string, userID int64) ([]FollowListEntry, error) {
+	rows, err := tx.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	users := []FollowListEntry{}
+
+	for rows.Next() {
+		var u FollowListEntry
+		if err := rows.Scan(&u.ID, &u.Username, &u.DisplayName, &u.AvatarURL, &u.FollowedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
+
+func exportSessions(ctx context.Context, tx *sql.Tx, userID int64) ([]Session, error) {
+	query := `
+		SELECT id, user_id, user_agent, ip, created_at, last_seen_at, expiry
+		FROM sessions
+		WHERE user_id = $1
+		ORDER BY created_at
+	`
+
+	rows, err := tx.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	sessions := []Session{}
+
+	for rows.Next() {
+		var session Session
+		if err := rows.Scan(
+			&session.ID,
+			&session.UserID,
+			&session.UserAgent,
+			&session.IP,
+			&session.CreatedAt,
+			&session.LastSeenAt,
+			&session.Expiry,
+		); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
@@ -0,0 +1,82 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestCompleteExport(t *testing.T) {
+	ctx := context.Background()
+	expiry := time.Now().Add(time.Hour)
+	email := &OutboxEmail{Template: "data_export.templ", Username: "ana", Email: "ana@example.com"}
+
+	t.Run("should keep only the latest export of the user", func(t *testing.T) {
+		storage, mock := newTestDB(t)
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE data_exports\s+SET status = 'ready', token = \$1, archive = \$2, expiry = \$3`).
+			WithArgs("hash", []byte("archive"), expiry, int64(3)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`DELETE FROM data_exports\s+WHERE user_id = \(SELECT user_id FROM data_exports WHERE id = \$1\)\s+AND id <> \$1 AND status <> 'pending'`).
+			WithArgs(int64(3)).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectQuery(`INSERT INTO email_outbox`).
+			WithArgs("data_export.templ", "ana", "ana@example.com", sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, "2026-01-01"))
+		mock.ExpectCommit()
+
+		if err := storage.Exports.Complete(ctx, 3, "hash", []byte("archive"), expiry, email); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("should not complete an export that is no longer pending", func(t *testing.T) {
+		storage, mock := newTestDB(t)
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE data_exports`).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		if err := storage.Exports.Complete(ctx, 3, "hash", []byte("archive"), expiry, email); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestGetExportArchive(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should return the archive of the token", func(t *testing.T) {
+		storage, mock := newTestDB(t)
+
+		mock.ExpectQuery(`SELECT archive FROM data_exports\s+WHERE id = \$1 AND token = \$2 AND status = 'ready' AND expiry > NOW\(\)`).
+			WithArgs(int64(3), "hash").
+			WillReturnRows(sqlmock.NewRows([]string{"archive"}).AddRow([]byte("archive")))
+
+		archive, err := storage.Exports.GetArchive(ctx, 3, "hash")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(archive) != "archive" {
+			t.Errorf("expected the archive, got %q", archive)
+		}
+	})
+
+	t.Run("should not find an export with another token", func(t *testing.T) {
+		storage, mock := newTestDB(t)
+
+		mock.ExpectQuery(`SELECT archive FROM data_exports`).
+			WithArgs(int64(3), "other").
+			WillReturnRows(sqlmock.NewRows([]string{"archive"}))
+
+		if _, err := storage.Exports.GetArchive(ctx, 3, "other"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+}
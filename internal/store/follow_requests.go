@@ -0,0 +1,136 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+// FollowRequest is a pending request to follow a private account.
+type FollowRequest struct {
+	RequesterID int64  `json:"requester_id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	AvatarURL   string `json:"avatar_url"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// GetRequests lists the pending follow requests to userID, oldest first.
+func (s *FollowersStore) GetRequests(ctx context.Context, userID int64, pq PaginatedQuery) ([]FollowRequest, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+		SELECT u.id, u.username, u.display_name, u.avatar_url, fr.created_at
+		FROM follow_requests fr
+		INNER JOIN users u ON u.id = fr.requester_id
+		WHERE fr.user_id = $1 AND u.is_active
+		ORDER BY fr.created_at, u.id
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, userID, pq.Limit, pq.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	requests := []FollowRequest{}
+
+	for rows.Next() {
+		var fr FollowRequest
+		if err := rows.Scan(&fr.RequesterID, &fr.Username, &fr.DisplayName, &fr.AvatarURL, &fr.CreatedAt); err != nil {
+			return nil, err
+		}
+		requests = append(requests, fr)
+	}
+
+	return requests, rows.Err()
+}
+
+// ApproveRequest turns the follow request of requesterID into a follow.
+func (s *FollowersStore) ApproveRequest(ctx context.Context, userID int64, requesterID int64) error {
+	return withTransaction(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.deleteRequest(ctx, tx, userID, requesterID); err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `
+			INSERT INTO followers (follower_id, user_id)
+			VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`
+
+		_, err := tx.ExecContext(ctx, query, requesterID, userID)
+		return err
+	})
+}
+
+// RejectRequest discards the follow request of requesterID.
+func (s *FollowersStore) RejectRequest(ctx context.Context, userID int64, requesterID int64) error {
+	return withTransaction(s.db, ctx, func(tx *sql.Tx) error {
+		return s.deleteRequest(ctx, tx, userID, requesterID)
+	})
+}
+
+func (s *FollowersStore) deleteRequest(ctx context.Context, tx *sql.Tx, userID int64, requesterID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `DELETE FROM follow_requests WHERE user_id = $1 AND requester_id = $2`
+
+	res, err := tx.ExecContext(ctx, query, userID, requesterID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// approveAllRequests turns every pending follow request to userID into a
+// follow, for when the account stops being private, and returns the
+// requesters that now follow the user.
+func approveAllRequests(ctx context.Context, tx *sql.Tx, userID int64) ([]int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+		WITH requests AS (
+			DELETE FROM follow_requests WHERE user_id = $1
+			RETURNING requester_id
+		)
+		INSERT INTO followers (follower_id, user_id)
+		SELECT requester_id, $1 FROM requests
+		ON CONFLICT DO NOTHING
+		RETURNING follower_id
+	`
+
+	rows, err := tx.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requesterIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		requesterIDs = append(requesterIDs, id)
+	}
+
+	return requesterIDs, rows.Err()
+}
@@ -17,9 +17,64 @@ type Follower struct {
 	CreatedAt  string `json:"created_at"`
 }
 
-func (s *FollowersStore) Follow(ctx context.Context, followerID int64, userID int64) error {
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+// Follow makes followerID follow userID. Following a private account
+// creates a follow request instead, and requested is true.
+func (s *FollowersStore) Follow(ctx context.Context, followerID int64, userID int64) (requested bool, err error) {
+	err = withTransaction(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var isPrivate bool
+		if err := tx.QueryRowContext(ctx, `SELECT is_private FROM users WHERE id = $1 AND is_active`, userID).Scan(&isPrivate); err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		blocked, err := blockExists(ctx, tx, followerID, userID)
+		if err != nil {
+			return err
+		}
+
+		if blocked {
+			return ErrBlocked
+		}
+
+		if !isPrivate {
+			return s.insertFollower(ctx, tx, followerID, userID)
+		}
+
+		var following bool
+		query := `SELECT EXISTS (SELECT 1 FROM followers WHERE user_id = $1 AND follower_id = $2)`
+		if err := tx.QueryRowContext(ctx, query, userID, followerID).Scan(&following); err != nil {
+			return err
+		}
+
+		if following {
+			return ErrConflict
+		}
+
+		requested = true
 
+		query = `INSERT INTO follow_requests (user_id, requester_id) VALUES ($1, $2)`
+		if _, err := tx.ExecContext(ctx, query, userID, followerID); err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return ErrConflict
+			}
+			return err
+		}
+
+		return nil
+	})
+
+	return requested, err
+}
+
+func (s *FollowersStore) insertFollower(ctx context.Context, tx *sql.Tx, followerID int64, userID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
 	query := `
@@ -27,23 +82,33 @@ func (s *FollowersStore) Follow(ctx context.Context, followerID int64, userID in
 		VALUES ($1, $2);
 	`
 
-	_, err := s.db.ExecContext(ctx, query, followerID, userID)
+	_, err := tx.ExecContext(ctx, query, followerID, userID)
 
 	if err != nil {
 		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
 			return ErrConflict
 		}
+		// foreign key violation, the user to follow does not exist
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return ErrNotFound
+		}
 	}
 
 	return err
 }
 
+// Unfollow stops followerID from following userID, or withdraws the
+// pending follow request.
 func (s *FollowersStore) Unfollow(ctx context.Context, followerID int64, userID int64) error {
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 
 	defer cancel()
 
 	query := `
+		WITH requests AS (
+			DELETE FROM follow_requests
+			WHERE requester_id = $1 AND user_id = $2
+		)
 		DELETE FROM followers
 		WHERE follower_id = $1 AND user_id = $2 
 	`
@@ -52,3 +117,96 @@ func (s *FollowersStore) Unfollow(ctx context.Context, followerID int64, userID
 
 	return err
 }
+
+// CanView reports whether viewerID may see the posts and connections of
+// userID. It is false when userID blocked viewerID, or when the account is
+// private and viewerID is not one of its followers.
+func (s *FollowersStore) CanView(ctx context.Context, viewerID int64, userID int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+		SELECT
+			(NOT u.is_private OR u.id = $1 OR EXISTS (
+				SELECT 1 FROM followers f WHERE f.user_id = u.id AND f.follower_id = $1
+			))
+			AND NOT EXISTS (
+				SELECT 1 FROM user_blocks b WHERE b.blocker_id = u.id AND b.blocked_id = $1
+			)
+		FROM users u
+		WHERE u.id = $2
+	`
+
+	var allowed bool
+	if err := s.db.QueryRowContext(ctx, query, viewerID, userID).Scan(&allowed); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return false, ErrNotFound
+		default:
+			return false, err
+		}
+	}
+
+	return allowed, nil
+}
+
+// FollowListEntry is a user in a followers or following list.
+type FollowListEntry struct {
+	ID          int64  `json:"id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	AvatarURL   string `json:"avatar_url"`
+	FollowedAt  string `json:"followed_at"`
+}
+
+// GetFollowers lists the active users following userID, most recent first.
+func (s *FollowersStore) GetFollowers(ctx context.Context, userID int64, pq PaginatedQuery) ([]FollowListEntry, error) {
+	query := `
+		SELECT u.id, u.username, u.display_name, u.avatar_url, f.created_at
+		FROM followers f
+		INNER JOIN users u ON u.id = f.follower_id
+		WHERE f.user_id = $1 AND u.is_active
+		ORDER BY f.created_at DESC, u.id DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	return s.list(ctx, query, userID, pq)
+}
+
+// GetFollowing lists the active users userID follows, most recent first.
+func (s *FollowersStore) GetFollowing(ctx context.Context, userID int64, pq PaginatedQuery) ([]FollowListEntry, error) {
+	query := `
+		SELECT u.id, u.username, u.display_name, u.avatar_url, f.created_at
+		FROM followers f
+		INNER JOIN users u ON u.id = f.user_id
+		WHERE f.follower_id = $1 AND u.is_active
+		ORDER BY f.created_at DESC, u.id DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	return s.list(ctx, query, userID, pq)
+}
+
+func (s *FollowersStore) list(ctx context.Context, query string, userID int64, pq PaginatedQuery) ([]FollowListEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, pq.Limit, pq.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	users := []FollowListEntry{}
+
+	for rows.Next() {
+		var u FollowListEntry
+		if err := rows.Scan(&u.ID, &u.Username, &u.DisplayName, &u.AvatarURL, &u.FollowedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
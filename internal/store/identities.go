@@ -0,0 +1,132 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+)
+
+// Identity links an account of an external identity provider to a user.
+type Identity struct {
+	Provider  string
+	Subject   string
+	UserID    int64
+	Email     string
+	CreatedAt string
+}
+
+type IdentitiesStore struct {
+	db *sql.DB
+}
+
+func (s *IdentitiesStore) GetUserID(ctx context.Context, provider, subject string) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `SELECT user_id FROM user_identities WHERE provider = $1 AND subject = $2`
+
+	var userID int64
+	if err := s.db.QueryRowContext(ctx, query, provider, subject).Scan(&userID); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return 0, ErrNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return userID, nil
+}
+
+// LinkOrCreate links identity to the user with the same email, or creates
+// and activates user when there is none. The username is suffixed when it is
+// already taken.
+//
+// An inactive account with the email was registered by someone who never
+// proved they own it, so before it is activated and linked its password is
+// replaced with user's and its invitations and reset tokens are deleted.
+// Otherwise whoever registered it could log in to the provider user's
+// account.
+func (s *IdentitiesStore) LinkOrCreate(ctx context.Context, identity *Identity, user *User) error {
+	users := &UsersStore{db: s.db}
+
+	return withTransaction(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `SELECT id, is_active FROM users WHERE email = $1 FOR UPDATE`
+
+		var isActive bool
+		err := tx.QueryRowContext(ctx, query, identity.Email).Scan(&user.ID, &isActive)
+
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			username, err := availableUsername(ctx, tx, user.Username)
+			if err != nil {
+				return err
+			}
+			user.Username = username
+
+			if err := users.Create(ctx, tx, user); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case !isActive:
+			if err := users.updatePassword(ctx, tx, user.ID, user.Password.hash); err != nil {
+				return err
+			}
+
+			if err := users.deletePasswordResets(ctx, tx, user.ID); err != nil {
+				return err
+			}
+		}
+
+		if !isActive {
+			// the provider verified the email, which is what the invitation
+			// would have proven
+			if err := users.updateActive(ctx, tx, user.ID); err != nil {
+				return err
+			}
+
+			if err := users.deleteUserInvitations(ctx, tx, user.ID); err != nil {
+				return err
+			}
+		}
+
+		identity.UserID = user.ID
+
+		query = `
+			INSERT INTO user_identities (provider, subject, user_id, email)
+			VALUES ($1, $2, $3, $4) RETURNING created_at
+		`
+
+		return tx.QueryRowContext(ctx, query, identity.Provider, identity.Subject, identity.UserID, identity.Email).Scan(&identity.CreatedAt)
+	})
+}
+
+func availableUsername(ctx context.Context, tx *sql.Tx, username string) (string, error) {
+	candidate := username
+
+	for i := 0; i < 5; i++ {
+		var exists bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE username = $1)`, candidate).Scan(&exists); err != nil {
+			return "", err
+		}
+
+		if !exists {
+			return candidate, nil
+		}
+
+		suffix := make([]byte, 3)
+		if _, err := rand.Read(suffix); err != nil {
+			return "", err
+		}
+
+		candidate = username + "_" + hex.EncodeToString(suffix)
+	}
+
+	return "", ErrDuplicateUsername
+}
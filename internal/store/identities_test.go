@@ -0,0 +1,73 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestLinkOrCreateIdentity(t *testing.T) {
+	ctx := context.Background()
+
+	newUser := func(t *testing.T) *User {
+		user := &User{Username: "ana", Email: "ana@example.com"}
+		if err := user.Password.Set("random provider password", DefaultPasswordHashing()); err != nil {
+			t.Fatal(err)
+		}
+		return user
+	}
+
+	t.Run("should take over an inactive account registered with the email", func(t *testing.T) {
+		storage, mock := newTestDB(t)
+		user := newUser(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT id, is_active FROM users WHERE email = \$1 FOR UPDATE`).
+			WithArgs("ana@example.com").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "is_active"}).AddRow(7, false))
+		mock.ExpectExec(`UPDATE users SET password = \$1 WHERE id = \$2`).
+			WithArgs(user.Password.hash, int64(7)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`DELETE FROM password_resets WHERE user_id = \$1`).
+			WithArgs(int64(7)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`UPDATE users SET is_active = true WHERE id = \$1`).
+			WithArgs(int64(7)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`DELETE FROM user_invitations WHERE user_id = \$1`).
+			WithArgs(int64(7)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(`INSERT INTO user_identities`).
+			WithArgs("google", "sub", int64(7), "ana@example.com").
+			WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow("2026-01-01"))
+		mock.ExpectCommit()
+
+		identity := &Identity{Provider: "google", Subject: "sub", Email: "ana@example.com"}
+		if err := storage.Identities.LinkOrCreate(ctx, identity, user); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("should link an active account as it is", func(t *testing.T) {
+		storage, mock := newTestDB(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT id, is_active FROM users`).
+			WithArgs("ana@example.com").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "is_active"}).AddRow(7, true))
+		mock.ExpectQuery(`INSERT INTO user_identities`).
+			WithArgs("google", "sub", int64(7), "ana@example.com").
+			WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow("2026-01-01"))
+		mock.ExpectCommit()
+
+		identity := &Identity{Provider: "google", Subject: "sub", Email: "ana@example.com"}
+		if err := storage.Identities.LinkOrCreate(ctx, identity, newUser(t)); err != nil {
+			t.Fatal(err)
+		}
+
+		if identity.UserID != 7 {
+			t.Errorf("expected the identity to be linked to user 7, got %d", identity.UserID)
+		}
+	})
+}
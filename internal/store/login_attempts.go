@@ -0,0 +1,114 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type LoginAttemptsStore struct {
+	db *sql.DB
+}
+
+// LockedFor returns how long the key remains locked, 0 if it is not.
+func (s *LoginAttemptsStore) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+		SELECT locked_until FROM login_attempts
+		WHERE key = $1 AND locked_until > NOW()
+	`
+
+	var lockedUntil time.Time
+	if err := s.db.QueryRowContext(ctx, query, key).Scan(&lockedUntil); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return 0, nil
+		default:
+			return 0, err
+		}
+	}
+
+	return time.Until(lockedUntil), nil
+}
+
+// RegisterFailure counts a failed attempt in the current window and locks
+// the key once maxAttempts is reached. It reports whether this failure
+// locked the key.
+func (s *LoginAttemptsStore) RegisterFailure(ctx context.Context, key string, maxAttempts int, window, lockout time.Duration) (bool, error) {
+	locked := false
+
+	err := withTransaction(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `
+			INSERT INTO login_attempts (key, failures, window_start) VALUES ($1, 1, NOW())
+			ON CONFLICT (key) DO UPDATE SET
+				failures = CASE
+					WHEN login_attempts.window_start < NOW() - make_interval(secs => $2) THEN 1
+					ELSE login_attempts.failures + 1
+				END,
+				window_start = CASE
+					WHEN login_attempts.window_start < NOW() - make_interval(secs => $2) THEN NOW()
+					ELSE login_attempts.window_start
+				END
+			RETURNING failures
+		`
+
+		var failures int
+		if err := tx.QueryRowContext(ctx, query, key, window.Seconds()).Scan(&failures); err != nil {
+			return err
+		}
+
+		if failures < maxAttempts {
+			return nil
+		}
+
+		query = `
+			UPDATE login_attempts
+			SET failures = 0, window_start = NOW(), locked_until = NOW() + make_interval(secs => $2)
+			WHERE key = $1
+		`
+
+		if _, err := tx.ExecContext(ctx, query, key, lockout.Seconds()); err != nil {
+			return err
+		}
+
+		locked = true
+		return nil
+	})
+
+	return locked, err
+}
+
+func (s *LoginAttemptsStore) Reset(ctx context.Context, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `DELETE FROM login_attempts WHERE key = $1`
+
+	_, err := s.db.ExecContext(ctx, query, key)
+	return err
+}
+
+// DeleteExpired removes the keys whose window and lockout are both over, as
+// their failures no longer count.
+func (s *LoginAttemptsStore) DeleteExpired(ctx context.Context, window time.Duration) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+		DELETE FROM login_attempts
+		WHERE window_start < NOW() - make_interval(secs => $1)
+			AND (locked_until IS NULL OR locked_until < NOW())
+	`
+
+	res, err := s.db.ExecContext(ctx, query, window.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,30 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestDeleteExpiredLoginAttempts(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should delete the keys whose window and lockout are over", func(t *testing.T) {
+		storage, mock := newTestDB(t)
+
+		mock.ExpectExec(`DELETE FROM login_attempts\s+WHERE window_start < NOW\(\) - make_interval\(secs => \$1\)\s+AND \(locked_until IS NULL OR locked_until < NOW\(\)\)`).
+			WithArgs(float64(900)).
+			WillReturnResult(sqlmock.NewResult(0, 4))
+
+		deleted, err := storage.LoginAttempts.DeleteExpired(ctx, 15*time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if deleted != 4 {
+			t.Errorf("expected 4 deleted keys, got %d", deleted)
+		}
+	})
+}
@@ -0,0 +1,82 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type MagicLinksStore struct {
+	db *sql.DB
+}
+
+// Create stores the hashed token of a login link for the user and queues
+// the email with the link in the same transaction.
+func (s *MagicLinksStore) Create(ctx context.Context, userID int64, token string, exp time.Duration, email *OutboxEmail) error {
+	return withTransaction(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `
+			INSERT INTO magic_links (token, user_id, expiry)
+			VALUES ($1, $2, $3)
+		`
+
+		if _, err := tx.ExecContext(ctx, query, token, userID, time.Now().Add(exp)); err != nil {
+			return err
+		}
+
+		return enqueueEmail(ctx, tx, email)
+	})
+}
+
+// Consume returns the active user the link of the hashed token belongs to
+// and deletes the link, so it can only be used once.
+func (s *MagicLinksStore) Consume(ctx context.Context, token string) (*User, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+		WITH link AS (
+			DELETE FROM magic_links
+			WHERE token = $1
+			RETURNING user_id, expiry
+		)
+		SELECT u.id, u.username, u.email, u.created_at, u.language, u.totp_enabled
+		FROM users u
+		INNER JOIN link l ON u.id = l.user_id
+		WHERE l.expiry > $2 AND u.is_active
+	`
+
+	user := &User{}
+
+	if err := s.db.QueryRowContext(ctx, query, token, time.Now()).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.CreatedAt,
+		&user.Language,
+		&user.TwoFactorEnabled,
+	); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+func (s *MagicLinksStore) DeleteExpired(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM magic_links WHERE expiry < NOW()`)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
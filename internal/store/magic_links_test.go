@@ -0,0 +1,65 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestConsumeMagicLink(t *testing.T) {
+	ctx := context.Background()
+
+	// the handlers hash the token of the link
+	hashed := "hash"
+
+	t.Run("should delete the link it logs in with", func(t *testing.T) {
+		storage, mock := newTestDB(t)
+
+		mock.ExpectQuery(`DELETE FROM magic_links\s+WHERE token = \$1\s+RETURNING user_id, expiry .+ WHERE l.expiry > \$2 AND u.is_active`).
+			WithArgs(hashed, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "created_at", "language", "totp_enabled"}).
+				AddRow(7, "ana", "ana@example.com", "2026-01-01", "en", false))
+
+		user, err := storage.MagicLinks.Consume(ctx, hashed)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if user.ID != 7 {
+			t.Errorf("expected user 7 to log in, got %d", user.ID)
+		}
+	})
+
+	t.Run("should not find used or expired links", func(t *testing.T) {
+		storage, mock := newTestDB(t)
+
+		mock.ExpectQuery(`DELETE FROM magic_links`).
+			WithArgs(hashed, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "created_at", "language", "totp_enabled"}))
+
+		if _, err := storage.MagicLinks.Consume(ctx, hashed); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestCreateMagicLink(t *testing.T) {
+	storage, mock := newTestDB(t)
+	email := &OutboxEmail{Template: "magic_link.templ", Username: "ana", Email: "ana@example.com"}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO magic_links \(token, user_id, expiry\)`).
+		WithArgs("hash", int64(7), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO email_outbox`).
+		WithArgs("magic_link.templ", "ana", "ana@example.com", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, "2026-01-01"))
+	mock.ExpectCommit()
+
+	if err := storage.MagicLinks.Create(context.Background(), 7, "hash", time.Minute, email); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -4,32 +4,442 @@ import (
 	"context"
 	"database/sql"
 	"time"
+
+	"github.com/stretchr/testify/mock"
 )
 
 func NewMockStore() Storage {
 	return Storage{
-		Users: &MockUserStore{},
+		Users:         &MockUserStore{},
+		Posts:         &MockPostsStore{},
+		RevokedTokens: &MockRevokedTokensStore{},
+		Identities:    &MockIdentitiesStore{},
+		Followers:     &MockFollowersStore{},
+		Suggestions:   &MockSuggestionsStore{},
+		Exports:       &MockExportsStore{},
+		Blocks:        &MockBlocksStore{},
+		Mutes:         &MockMutesStore{},
+		RefreshTokens: &MockRefreshTokensStore{},
+		LoginAttempts: &MockLoginAttemptsStore{},
+		Sessions:      &MockSessionsStore{},
+		AccessTokens:  &MockAccessTokensStore{},
+		Outbox:        &MockOutboxStore{},
+		MagicLinks:    &MockMagicLinksStore{},
 	}
 }
 
-type MockUserStore struct{}
+// MockUserStore answers with the expectations set by each test.
+type MockUserStore struct {
+	mock.Mock
+}
+
+func (s *MockUserStore) user(args mock.Arguments) (*User, error) {
+	user, _ := args.Get(0).(*User)
+	return user, args.Error(1)
+}
+
+func (s *MockUserStore) count(args mock.Arguments) (int64, error) {
+	return args.Get(0).(int64), args.Error(1)
+}
 
 func (s *MockUserStore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
-	return nil
+	return s.Called(user).Error(0)
 }
 func (s *MockUserStore) GetByID(ctx context.Context, userID int64) (*User, error) {
-	return &User{}, nil
+	return s.user(s.Called(userID))
+}
+func (s *MockUserStore) CreateAndInvite(ctx context.Context, user *User, token string, invitationExp time.Duration, email *OutboxEmail) error {
+	return s.Called(user, token, email).Error(0)
+}
+func (s *MockUserStore) Activate(ctx context.Context, token string) error {
+	return s.Called(token).Error(0)
+}
+func (s *MockUserStore) Delete(ctx context.Context, userID int64) error {
+	return s.Called(userID).Error(0)
+}
+func (s *MockUserStore) GetByEmail(ctx context.Context, email string) (*User, error) {
+	return s.user(s.Called(email))
+}
+func (s *MockUserStore) CreatePasswordReset(ctx context.Context, userID int64, token string, exp time.Duration, email *OutboxEmail) error {
+	return s.Called(userID, token, email).Error(0)
+}
+func (s *MockUserStore) ResetPassword(ctx context.Context, token string, user *User) error {
+	return s.Called(token, user).Error(0)
+}
+func (s *MockUserStore) ChangePassword(ctx context.Context, user *User, email *OutboxEmail) error {
+	return s.Called(user, email).Error(0)
+}
+func (s *MockUserStore) RehashPassword(ctx context.Context, user *User, text string) error {
+	return s.Called(user, text).Error(0)
+}
+func (s *MockUserStore) PasswordHashReport(ctx context.Context) (*PasswordHashReport, error) {
+	args := s.Called()
+	report, _ := args.Get(0).(*PasswordHashReport)
+	return report, args.Error(1)
+}
+func (s *MockUserStore) SetTOTPSecret(ctx context.Context, userID int64, secret string) error {
+	return s.Called(userID, secret).Error(0)
+}
+func (s *MockUserStore) GetTOTPSecret(ctx context.Context, userID int64) (string, error) {
+	args := s.Called(userID)
+	return args.String(0), args.Error(1)
+}
+func (s *MockUserStore) EnableTOTP(ctx context.Context, userID int64, recoveryCodes []string) error {
+	return s.Called(userID, recoveryCodes).Error(0)
+}
+func (s *MockUserStore) DisableTOTP(ctx context.Context, userID int64) error {
+	return s.Called(userID).Error(0)
+}
+func (s *MockUserStore) UseRecoveryCode(ctx context.Context, userID int64, code string) error {
+	return s.Called(userID, code).Error(0)
+}
+func (s *MockUserStore) UseTOTPStep(ctx context.Context, userID int64, step int64) error {
+	return s.Called(userID, step).Error(0)
+}
+func (s *MockUserStore) GetPendingActivation(ctx context.Context, email string) (*User, error) {
+	return s.user(s.Called(email))
+}
+func (s *MockUserStore) RotateInvitation(ctx context.Context, userID int64, token string, invitationExp time.Duration, email *OutboxEmail) error {
+	return s.Called(userID, token, email).Error(0)
+}
+func (s *MockUserStore) DeleteExpiredInvitations(ctx context.Context) (int64, error) {
+	return s.count(s.Called())
+}
+func (s *MockUserStore) DeleteUnactivated(ctx context.Context, gracePeriod time.Duration) (int64, error) {
+	return s.count(s.Called(gracePeriod))
+}
+func (s *MockUserStore) UpdateProfile(ctx context.Context, user *User, change *EmailChange) ([]int64, error) {
+	args := s.Called(user, change)
+	requesterIDs, _ := args.Get(0).([]int64)
+	return requesterIDs, args.Error(1)
+}
+func (s *MockUserStore) ConfirmEmailChange(ctx context.Context, token string) (*User, error) {
+	return s.user(s.Called(token))
+}
+func (s *MockUserStore) DeleteExpiredEmailChanges(ctx context.Context) (int64, error) {
+	return s.count(s.Called())
+}
+func (s *MockUserStore) Search(ctx context.Context, viewerID int64, term string, pq PaginatedQuery) ([]UserSearchResult, error) {
+	args := s.Called(viewerID, term, pq)
+	results, _ := args.Get(0).([]UserSearchResult)
+	return results, args.Error(1)
+}
+func (s *MockUserStore) ScheduleDeletion(ctx context.Context, userID int64, at time.Time, email *OutboxEmail) error {
+	return s.Called(userID, at, email).Error(0)
+}
+func (s *MockUserStore) CancelDeletion(ctx context.Context, userID int64) error {
+	return s.Called(userID).Error(0)
+}
+func (s *MockUserStore) GetDueDeletions(ctx context.Context, limit int) ([]int64, error) {
+	args := s.Called(limit)
+	ids, _ := args.Get(0).([]int64)
+	return ids, args.Error(1)
+}
+func (s *MockUserStore) DeleteScheduled(ctx context.Context, userID int64) error {
+	return s.Called(userID).Error(0)
+}
+
+// MockRevokedTokensStore remembers the revoked token and session ids, so
+// tests can check that revoked tokens are rejected.
+type MockRevokedTokensStore struct {
+	revoked map[string]bool
 }
 
-func (s *MockUserStore) CreateAndInvite(ctx context.Context, user *User, token string, invitationExp time.Duration) error {
+func (s *MockRevokedTokensStore) Revoke(ctx context.Context, jti string, exp time.Time) error {
+	if s.revoked == nil {
+		s.revoked = map[string]bool{}
+	}
+	s.revoked[jti] = true
 	return nil
 }
-func (s *MockUserStore) Activate(ctx context.Context, token string) error {
+func (s *MockRevokedTokensStore) RevokeUser(ctx context.Context, userID int64, exp time.Time) error {
 	return nil
 }
-func (s *MockUserStore) Delete(ctx context.Context, userID int64) error {
+func (s *MockRevokedTokensStore) RevokeSession(ctx context.Context, sessionID string, exp time.Time) error {
+	return s.Revoke(ctx, sessionID, exp)
+}
+func (s *MockRevokedTokensStore) IsRevoked(ctx context.Context, jti string, sessionID string, userID int64, issuedAt time.Time) (bool, error) {
+	return s.revoked[jti] || s.revoked[sessionID], nil
+}
+
+// MockLoginAttemptsStore counts failures in memory and locks a key once it
+// reaches maxAttempts. Windows and lockouts do not expire.
+type MockLoginAttemptsStore struct {
+	failures map[string]int
+	locked   map[string]time.Duration
+}
+
+func (s *MockLoginAttemptsStore) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	return s.locked[key], nil
+}
+func (s *MockLoginAttemptsStore) RegisterFailure(ctx context.Context, key string, maxAttempts int, window, lockout time.Duration) (bool, error) {
+	if s.failures == nil {
+		s.failures = map[string]int{}
+		s.locked = map[string]time.Duration{}
+	}
+
+	s.failures[key]++
+	if s.failures[key] < maxAttempts {
+		return false, nil
+	}
+
+	s.failures[key] = 0
+	s.locked[key] = lockout
+	return true, nil
+}
+func (s *MockLoginAttemptsStore) Reset(ctx context.Context, key string) error {
+	delete(s.failures, key)
+	return nil
+}
+func (s *MockLoginAttemptsStore) DeleteExpired(ctx context.Context, window time.Duration) (int64, error) {
+	return 0, nil
+}
+
+type MockIdentitiesStore struct{}
+
+func (s *MockIdentitiesStore) GetUserID(ctx context.Context, provider, subject string) (int64, error) {
+	return 0, ErrNotFound
+}
+func (s *MockIdentitiesStore) LinkOrCreate(ctx context.Context, identity *Identity, user *User) error {
+	user.ID = 1
+	identity.UserID = user.ID
+	return nil
+}
+
+// MockFollowersStore records the calls that follow users and handle follow
+// requests.
+type MockFollowersStore struct {
+	mock.Mock
+}
+
+func (s *MockFollowersStore) Follow(ctx context.Context, followerID int64, userID int64) (bool, error) {
+	args := s.Called(followerID, userID)
+	return args.Bool(0), args.Error(1)
+}
+func (s *MockFollowersStore) Unfollow(ctx context.Context, followerID int64, userID int64) error {
 	return nil
 }
-func (s *MockUserStore) GetByEmail(ctx context.Context, Email string) (*User, error) {
-	return &User{}, nil
+func (s *MockFollowersStore) GetFollowers(ctx context.Context, userID int64, pq PaginatedQuery) ([]FollowListEntry, error) {
+	return []FollowListEntry{}, nil
+}
+func (s *MockFollowersStore) GetFollowing(ctx context.Context, userID int64, pq PaginatedQuery) ([]FollowListEntry, error) {
+	return []FollowListEntry{}, nil
+}
+func (s *MockFollowersStore) CanView(ctx context.Context, viewerID int64, userID int64) (bool, error) {
+	return true, nil
+}
+func (s *MockFollowersStore) GetRequests(ctx context.Context, userID int64, pq PaginatedQuery) ([]FollowRequest, error) {
+	args := s.Called(userID, pq)
+	requests, _ := args.Get(0).([]FollowRequest)
+	return requests, args.Error(1)
+}
+func (s *MockFollowersStore) ApproveRequest(ctx context.Context, userID int64, requesterID int64) error {
+	return s.Called(userID, requesterID).Error(0)
+}
+func (s *MockFollowersStore) RejectRequest(ctx context.Context, userID int64, requesterID int64) error {
+	return s.Called(userID, requesterID).Error(0)
+}
+
+// MockBlocksStore records the calls that block and unblock users.
+type MockBlocksStore struct {
+	mock.Mock
+}
+
+func (s *MockBlocksStore) Block(ctx context.Context, blockerID int64, blockedID int64) error {
+	return s.Called(blockerID, blockedID).Error(0)
+}
+func (s *MockBlocksStore) Unblock(ctx context.Context, blockerID int64, blockedID int64) error {
+	return s.Called(blockerID, blockedID).Error(0)
+}
+func (s *MockBlocksStore) ExistsBetween(ctx context.Context, userID int64, otherID int64) (bool, error) {
+	return false, nil
+}
+func (s *MockBlocksStore) GetBlocked(ctx context.Context, blockerID int64, pq PaginatedQuery) ([]RelatedUser, error) {
+	return []RelatedUser{}, nil
+}
+
+// MockMutesStore records the calls that mute and unmute users.
+type MockMutesStore struct {
+	mock.Mock
+}
+
+func (s *MockMutesStore) Mute(ctx context.Context, muterID int64, mutedID int64) error {
+	return s.Called(muterID, mutedID).Error(0)
+}
+func (s *MockMutesStore) Unmute(ctx context.Context, muterID int64, mutedID int64) error {
+	return s.Called(muterID, mutedID).Error(0)
+}
+func (s *MockMutesStore) GetMuted(ctx context.Context, muterID int64, pq PaginatedQuery) ([]RelatedUser, error) {
+	return []RelatedUser{}, nil
+}
+
+type MockSuggestionsStore struct {
+	mock.Mock
+}
+
+func (s *MockSuggestionsStore) Get(ctx context.Context, userID int64) ([]Suggestion, error) {
+	args := s.Called(userID)
+	suggestions, _ := args.Get(0).([]Suggestion)
+	return suggestions, args.Error(1)
+}
+
+// MockExportsStore records the exports that are created, completed and
+// downloaded.
+type MockExportsStore struct {
+	mock.Mock
+}
+
+func (s *MockExportsStore) Create(ctx context.Context, userID int64) (*DataExport, error) {
+	args := s.Called(userID)
+	export, _ := args.Get(0).(*DataExport)
+	return export, args.Error(1)
+}
+func (s *MockExportsStore) ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]DataExport, error) {
+	return []DataExport{}, nil
+}
+func (s *MockExportsStore) Complete(ctx context.Context, exportID int64, token string, archive []byte, expiry time.Time, email *OutboxEmail) error {
+	return s.Called(exportID, token, email).Error(0)
+}
+func (s *MockExportsStore) Fail(ctx context.Context, exportID int64) error {
+	return nil
+}
+func (s *MockExportsStore) GetArchive(ctx context.Context, exportID int64, token string) ([]byte, error) {
+	args := s.Called(exportID, token)
+	archive, _ := args.Get(0).([]byte)
+	return archive, args.Error(1)
+}
+func (s *MockExportsStore) DeleteExpired(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+func (s *MockExportsStore) CollectUserData(ctx context.Context, userID int64) (*UserData, error) {
+	return &UserData{Profile: &User{ID: userID}}, nil
+}
+
+// MockPostsStore records the feed queries, so tests can check what the
+// handlers ask for.
+type MockPostsStore struct {
+	mock.Mock
+}
+
+func (s *MockPostsStore) Create(ctx context.Context, post *Post) error {
+	return nil
+}
+func (s *MockPostsStore) GetByID(ctx context.Context, postID int64) (*Post, error) {
+	return &Post{ID: postID}, nil
+}
+func (s *MockPostsStore) UpdateByID(ctx context.Context, post *Post) error {
+	return nil
+}
+func (s *MockPostsStore) DeleteByID(ctx context.Context, postID int64) error {
+	return nil
+}
+func (s *MockPostsStore) GetUserFeed(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
+	args := s.Called(userID, fq)
+	return []PostWithMetadata{}, args.Error(1)
+}
+
+// MockRefreshTokensStore lets tests decide how each rotation ends.
+type MockRefreshTokensStore struct {
+	mock.Mock
+}
+
+func (s *MockRefreshTokensStore) Rotate(ctx context.Context, token string, next *RefreshToken) error {
+	args := s.Called(token)
+	if args.Error(0) == nil {
+		next.UserID = 1
+		next.FamilyID = "family"
+	}
+	return args.Error(0)
+}
+func (s *MockRefreshTokensStore) RevokeFamily(ctx context.Context, familyID string) error {
+	return s.Called(familyID).Error(0)
+}
+func (s *MockRefreshTokensStore) RevokeByUser(ctx context.Context, userID int64) ([]string, error) {
+	args := s.Called(userID)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+// MockSessionsStore records the calls that list, end and touch sessions.
+type MockSessionsStore struct {
+	mock.Mock
+}
+
+func (s *MockSessionsStore) Create(ctx context.Context, session *Session, token *RefreshToken) error {
+	return nil
+}
+func (s *MockSessionsStore) GetByUserID(ctx context.Context, userID int64) ([]Session, error) {
+	args := s.Called(userID)
+	sessions, _ := args.Get(0).([]Session)
+	return sessions, args.Error(1)
+}
+func (s *MockSessionsStore) Revoke(ctx context.Context, sessionID string, userID int64) error {
+	return s.Called(sessionID, userID).Error(0)
+}
+func (s *MockSessionsStore) RevokeOthers(ctx context.Context, userID int64, currentSessionID string) ([]string, error) {
+	return []string{}, nil
+}
+func (s *MockSessionsStore) Touch(ctx context.Context, sessionID string) error {
+	return s.Called(sessionID).Error(0)
+}
+func (s *MockSessionsStore) DeleteExpired(ctx context.Context, revokedBefore time.Time) (int64, error) {
+	return 0, nil
+}
+
+// MockAccessTokensStore lets tests decide which personal access tokens
+// authenticate.
+type MockAccessTokensStore struct {
+	mock.Mock
+}
+
+func (s *MockAccessTokensStore) Create(ctx context.Context, token *AccessToken) error {
+	return nil
+}
+func (s *MockAccessTokensStore) GetByUserID(ctx context.Context, userID int64) ([]AccessToken, error) {
+	return []AccessToken{}, nil
+}
+func (s *MockAccessTokensStore) Authenticate(ctx context.Context, token string) (*AccessToken, error) {
+	args := s.Called(token)
+	accessToken, _ := args.Get(0).(*AccessToken)
+	return accessToken, args.Error(1)
+}
+func (s *MockAccessTokensStore) Delete(ctx context.Context, tokenID int64, userID int64) error {
+	return nil
+}
+func (s *MockAccessTokensStore) DeleteByUser(ctx context.Context, userID int64) error {
+	return s.Called(userID).Error(0)
+}
+
+type MockOutboxStore struct{}
+
+func (s *MockOutboxStore) Enqueue(ctx context.Context, email *OutboxEmail) error {
+	return nil
+}
+func (s *MockOutboxStore) ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]OutboxEmail, error) {
+	return []OutboxEmail{}, nil
+}
+func (s *MockOutboxStore) MarkSent(ctx context.Context, id int64) error {
+	return nil
+}
+func (s *MockOutboxStore) MarkFailed(ctx context.Context, id int64, lastError string, nextAttempt time.Time, dead bool) error {
+	return nil
+}
+func (s *MockOutboxStore) DeleteOld(ctx context.Context, retention time.Duration) (int64, error) {
+	return 0, nil
+}
+
+// MockMagicLinksStore lets tests decide which links can be consumed.
+type MockMagicLinksStore struct {
+	mock.Mock
+}
+
+func (s *MockMagicLinksStore) Create(ctx context.Context, userID int64, token string, exp time.Duration, email *OutboxEmail) error {
+	return s.Called(userID, exp, email).Error(0)
+}
+func (s *MockMagicLinksStore) Consume(ctx context.Context, token string) (*User, error) {
+	args := s.Called(token)
+	user, _ := args.Get(0).(*User)
+	return user, args.Error(1)
+}
+func (s *MockMagicLinksStore) DeleteExpired(ctx context.Context) (int64, error) {
+	return 0, nil
 }
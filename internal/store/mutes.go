@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// MutesStore keeps the users each user muted. Their posts and comments are
+// left out of the muter's feed and of the comments of posts.
+type MutesStore struct {
+	db *sql.DB
+}
+
+func (s *MutesStore) Mute(ctx context.Context, muterID int64, mutedID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `INSERT INTO user_mutes (muter_id, muted_id) VALUES ($1, $2)`
+
+	_, err := s.db.ExecContext(ctx, query, muterID, mutedID)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return ErrNotFound
+		}
+	}
+
+	return err
+}
+
+func (s *MutesStore) Unmute(ctx context.Context, muterID int64, mutedID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `DELETE FROM user_mutes WHERE muter_id = $1 AND muted_id = $2`
+
+	res, err := s.db.ExecContext(ctx, query, muterID, mutedID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetMuted lists the users muterID muted, most recent first.
+func (s *MutesStore) GetMuted(ctx context.Context, muterID int64, pq PaginatedQuery) ([]RelatedUser, error) {
+	query := `
+		SELECT u.id, u.username, u.display_name, u.avatar_url, m.created_at
+		FROM user_mutes m
+		INNER JOIN users u ON u.id = m.muted_id
+		WHERE m.muter_id = $1
+		ORDER BY m.created_at DESC, u.id DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	return listRelatedUsers(ctx, s.db, query, muterID, pq)
+}
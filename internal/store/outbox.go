@@ -0,0 +1,156 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+const (
+	OutboxStatusPending = "pending"
+	OutboxStatusSent    = "sent"
+	OutboxStatusDead    = "dead"
+)
+
+// OutboxEmail is an email waiting to be delivered by the dispatcher. Data
+// holds the template variables.
+type OutboxEmail struct {
+	ID        int64
+	Template  string
+	Username  string
+	Email     string
+	Data      json.RawMessage
+	Attempts  int
+	CreatedAt string
+}
+
+type OutboxStore struct {
+	db *sql.DB
+}
+
+func (s *OutboxStore) Enqueue(ctx context.Context, email *OutboxEmail) error {
+	return withTransaction(s.db, ctx, func(tx *sql.Tx) error {
+		return enqueueEmail(ctx, tx, email)
+	})
+}
+
+// enqueueEmail lets other stores write an email in the same transaction as
+// the change that triggers it.
+func enqueueEmail(ctx context.Context, tx *sql.Tx, email *OutboxEmail) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+		INSERT INTO email_outbox (template, username, email, data)
+		VALUES ($1, $2, $3, $4) RETURNING id, created_at
+	`
+
+	return tx.QueryRowContext(ctx, query, email.Template, email.Username, email.Email, email.Data).Scan(
+		&email.ID,
+		&email.CreatedAt,
+	)
+}
+
+// ClaimPending returns up to limit emails due for delivery and hides them
+// from other dispatchers for the lease duration.
+func (s *OutboxStore) ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]OutboxEmail, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+		UPDATE email_outbox SET next_attempt_at = $2
+		WHERE id IN (
+			SELECT id FROM email_outbox
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, template, username, email, data, attempts, created_at
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, limit, time.Now().Add(lease))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	emails := []OutboxEmail{}
+
+	for rows.Next() {
+		e := OutboxEmail{}
+		if err := rows.Scan(
+			&e.ID,
+			&e.Template,
+			&e.Username,
+			&e.Email,
+			&e.Data,
+			&e.Attempts,
+			&e.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		emails = append(emails, e)
+	}
+
+	return emails, rows.Err()
+}
+
+// MarkSent also clears the template data, since it may hold one-time links.
+func (s *OutboxStore) MarkSent(ctx context.Context, id int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+		UPDATE email_outbox
+		SET status = 'sent', sent_at = NOW(), attempts = attempts + 1, data = '{}', last_error = NULL
+		WHERE id = $1
+	`
+
+	_, err := s.db.ExecContext(ctx, query, id)
+	return err
+}
+
+// MarkFailed schedules another attempt at nextAttempt, or dead-letters the
+// email when dead is set. Dead-lettered emails lose their template data, like
+// sent ones.
+func (s *OutboxStore) MarkFailed(ctx context.Context, id int64, lastError string, nextAttempt time.Time, dead bool) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	status := OutboxStatusPending
+	if dead {
+		status = OutboxStatusDead
+	}
+
+	query := `
+		UPDATE email_outbox
+		SET status = $2, attempts = attempts + 1, last_error = $3, next_attempt_at = $4,
+			data = CASE WHEN $5 THEN '{}' ELSE data END
+		WHERE id = $1
+	`
+
+	_, err := s.db.ExecContext(ctx, query, id, status, lastError, nextAttempt, dead)
+	return err
+}
+
+// DeleteOld purges the sent and dead-lettered emails created before the
+// retention period.
+func (s *OutboxStore) DeleteOld(ctx context.Context, retention time.Duration) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+		DELETE FROM email_outbox
+		WHERE status IN ('sent', 'dead') AND created_at < $1
+	`
+
+	res, err := s.db.ExecContext(ctx, query, time.Now().Add(-retention))
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
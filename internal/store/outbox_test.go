@@ -0,0 +1,43 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestOutboxRetention(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should clear the data of dead-lettered emails", func(t *testing.T) {
+		storage, mock := newTestDB(t)
+		next := time.Now()
+
+		mock.ExpectExec(`UPDATE email_outbox SET .+ data = CASE WHEN \$5 THEN '\{\}' ELSE data END`).
+			WithArgs(int64(1), OutboxStatusDead, "bounced", next, true).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		if err := storage.Outbox.MarkFailed(ctx, 1, "bounced", next, true); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("should purge the old sent and dead emails", func(t *testing.T) {
+		storage, mock := newTestDB(t)
+
+		mock.ExpectExec(`DELETE FROM email_outbox\s+WHERE status IN \('sent', 'dead'\) AND created_at < \$1`).
+			WithArgs(sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 3))
+
+		deleted, err := storage.Outbox.DeleteOld(ctx, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if deleted != 3 {
+			t.Errorf("expected 3 emails to be purged, got %d", deleted)
+		}
+	})
+}
@@ -1,6 +1,8 @@
 package store
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
@@ -24,7 +26,7 @@ func (fq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error)
 	if limit != "" {
 		l, err := strconv.Atoi(limit)
 		if err != nil {
-			return fq, nil
+			return fq, fmt.Errorf("invalid limit: %w", err)
 		}
 
 		fq.Limit = l
@@ -34,7 +36,7 @@ func (fq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error)
 	if offset != "" {
 		o, err := strconv.Atoi(offset)
 		if err != nil {
-			return fq, nil
+			return fq, fmt.Errorf("invalid offset: %w", err)
 		}
 
 		fq.Offset = o
@@ -57,22 +59,68 @@ func (fq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error)
 
 	since := qs.Get("since")
 	if since != "" {
-		fq.Since = parseTime(since)
+		t, err := parseTime(since)
+		if err != nil {
+			return fq, fmt.Errorf("invalid since: %w", err)
+		}
+		fq.Since = t
 	}
 
 	until := qs.Get("until")
 	if until != "" {
-		fq.Until = parseTime(until)
+		t, err := parseTime(until)
+		if err != nil {
+			return fq, fmt.Errorf("invalid until: %w", err)
+		}
+		fq.Until = t
+	}
+
+	if fq.Since != "" && fq.Until != "" && fq.Since > fq.Until {
+		return fq, errors.New("since must not be after until")
 	}
 
 	return fq, nil
 }
 
-func parseTime(s string) string {
+// parseTime accepts times in the time.DateTime layout, in UTC, or in
+// RFC 3339. It returns them in RFC 3339 and UTC, so they compare as strings.
+func parseTime(s string) (string, error) {
 	t, err := time.Parse(time.DateTime, s)
 	if err != nil {
-		return ""
+		if t, err = time.Parse(time.RFC3339, s); err != nil {
+			return "", fmt.Errorf("%q is not a date time like 2006-01-02 15:04:05", s)
+		}
+	}
+
+	return t.UTC().Format(time.RFC3339), nil
+}
+
+// PaginatedQuery pages through lists that have no filters of their own.
+type PaginatedQuery struct {
+	Limit  int `json:"limit" validate:"gte=1,lte=100"`
+	Offset int `json:"offset" validate:"gte=0"`
+}
+
+func (pq PaginatedQuery) Parse(r *http.Request) (PaginatedQuery, error) {
+	qs := r.URL.Query()
+
+	if limit := qs.Get("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return pq, err
+		}
+
+		pq.Limit = l
+	}
+
+	if offset := qs.Get("offset"); offset != "" {
+		o, err := strconv.Atoi(offset)
+		if err != nil {
+			return pq, err
+		}
+
+		pq.Offset = o
 	}
-	return t.Format(time.DateTime)
 
+	return pq, nil
 }
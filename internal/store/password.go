@@ -0,0 +1,284 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	HashBcrypt   = "bcrypt"
+	HashArgon2id = "argon2id"
+)
+
+// Argon2Params are the argon2id cost parameters. Memory is in KiB.
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// PasswordHashing configures how passwords are hashed. Hashes made with
+// other settings still verify and are upgraded on the next login.
+type PasswordHashing struct {
+	// Algorithm is bcrypt or argon2id
+	Algorithm  string
+	BcryptCost int
+	Argon2     Argon2Params
+}
+
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// DefaultPasswordHashing is the hashing used when none is configured.
+func DefaultPasswordHashing() PasswordHashing {
+	return PasswordHashing{
+		Algorithm:  HashBcrypt,
+		BcryptCost: bcrypt.DefaultCost,
+		Argon2:     DefaultArgon2Params,
+	}
+}
+
+var errInvalidArgon2Hash = errors.New("invalid argon2id hash")
+
+func (h PasswordHashing) Validate() error {
+	switch h.Algorithm {
+	case HashBcrypt:
+		if h.BcryptCost < bcrypt.MinCost || h.BcryptCost > bcrypt.MaxCost {
+			return fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	case HashArgon2id:
+		p := h.Argon2
+		if p.Memory == 0 || p.Iterations == 0 || p.Parallelism == 0 || p.SaltLength == 0 || p.KeyLength == 0 {
+			return errors.New("argon2id parameters must be positive")
+		}
+	default:
+		return fmt.Errorf("unknown password hash algorithm %q", h.Algorithm)
+	}
+
+	return nil
+}
+
+// scheme identifies the algorithm and cost of the hashes h makes, in the
+// form hashScheme returns for them.
+func (h PasswordHashing) scheme() string {
+	if h.Algorithm == HashArgon2id {
+		return fmt.Sprintf("argon2id$v=%d$m=%d,t=%d,p=%d", argon2.Version, h.Argon2.Memory, h.Argon2.Iterations, h.Argon2.Parallelism)
+	}
+	return fmt.Sprintf("bcrypt$%d", h.BcryptCost)
+}
+
+func (h PasswordHashing) hash(text string) ([]byte, error) {
+	if h.Algorithm != HashArgon2id {
+		return bcrypt.GenerateFromPassword([]byte(text), h.BcryptCost)
+	}
+
+	p := h.Argon2
+
+	salt := make([]byte, p.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	key := argon2.IDKey([]byte(text), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+
+	return []byte(fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)), nil
+}
+
+// hashScheme returns the algorithm and cost a hash, or the leading part of
+// one, was made with. It is empty for unknown formats.
+func hashScheme(hash string) string {
+	parts := strings.Split(hash, "$")
+
+	switch {
+	case len(parts) >= 4 && parts[1] == HashArgon2id:
+		return strings.Join(parts[1:4], "$")
+	case len(parts) >= 3 && strings.HasPrefix(parts[1], "2"):
+		cost, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return ""
+		}
+		return fmt.Sprintf("bcrypt$%d", cost)
+	}
+
+	return ""
+}
+
+type password struct {
+	text *string
+	hash []byte
+}
+
+// Set hashes text with hashing.
+func (p *password) Set(text string, hashing PasswordHashing) error {
+	hash, err := hashing.hash(text)
+	if err != nil {
+		return err
+	}
+
+	p.text = &text
+	p.hash = hash
+
+	return nil
+}
+
+// Compare checks the password against the hash, whatever algorithm made it.
+// Mismatches return bcrypt.ErrMismatchedHashAndPassword for both algorithms.
+func (p *password) Compare(password string) error {
+	if !strings.HasPrefix(string(p.hash), "$argon2id$") {
+		return bcrypt.CompareHashAndPassword(p.hash, []byte(password))
+	}
+
+	parts := strings.Split(string(p.hash), "$")
+	if len(parts) != 6 {
+		return errInvalidArgon2Hash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return errInvalidArgon2Hash
+	}
+
+	var memory, iterations uint32
+	var parallelism uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &parallelism); err != nil {
+		return errInvalidArgon2Hash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return errInvalidArgon2Hash
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return errInvalidArgon2Hash
+	}
+
+	other := argon2.IDKey([]byte(password), salt, iterations, memory, parallelism, uint32(len(key)))
+
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return bcrypt.ErrMismatchedHashAndPassword
+	}
+
+	return nil
+}
+
+// NeedsRehash reports whether the hash was made with another algorithm or
+// cost than hashing.
+func (p *password) NeedsRehash(hashing PasswordHashing) bool {
+	return hashScheme(string(p.hash)) != hashing.scheme()
+}
+
+// RehashPassword stores a hash of text made with the hashing configuration
+// of the store. text must be the user's current password, which was just
+// verified. The hash is left alone if the password changed meanwhile.
+func (s *UsersStore) RehashPassword(ctx context.Context, user *User, text string) error {
+	previous := user.Password.hash
+
+	if err := user.Password.Set(text, s.hashing); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `UPDATE users SET password = $1 WHERE id = $2 AND password = $3`
+
+	_, err := s.db.ExecContext(ctx, query, user.Password.hash, user.ID, previous)
+	return err
+}
+
+// PasswordHashCount is how many accounts have hashes of a scheme, such as
+// bcrypt$10 or argon2id$v=19$m=65536,t=3,p=2.
+type PasswordHashCount struct {
+	Scheme string `json:"scheme"`
+	Count  int64  `json:"count"`
+	// Legacy hashes are upgraded the next time their user logs in
+	Legacy bool `json:"legacy"`
+}
+
+type PasswordHashReport struct {
+	Current string              `json:"current"`
+	Total   int64               `json:"total"`
+	Legacy  int64               `json:"legacy"`
+	Schemes []PasswordHashCount `json:"schemes"`
+}
+
+// PasswordHashReport counts the accounts per hash scheme, telling apart
+// those that do not match the hashing configuration of the store.
+func (s *UsersStore) PasswordHashReport(ctx context.Context) (*PasswordHashReport, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+		SELECT
+			CASE
+				WHEN h LIKE '$argon2id$%' THEN substring(h from '^\$argon2id\$[^$]*\$[^$]*')
+				ELSE substring(h from '^\$[^$]*\$[^$]*')
+			END AS prefix,
+			COUNT(*)
+		FROM (SELECT convert_from(password, 'UTF8') AS h FROM users) u
+		GROUP BY prefix
+	`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	current := s.hashing.scheme()
+	report := &PasswordHashReport{Current: current, Schemes: []PasswordHashCount{}}
+	counts := map[string]int{}
+
+	for rows.Next() {
+		var prefix sql.NullString
+		var count int64
+		if err := rows.Scan(&prefix, &count); err != nil {
+			return nil, err
+		}
+
+		// $2a$ and $2b$ bcrypt prefixes of the same cost share a scheme
+		scheme := hashScheme(prefix.String)
+		if scheme == "" {
+			scheme = "unknown"
+		}
+
+		if i, ok := counts[scheme]; ok {
+			report.Schemes[i].Count += count
+		} else {
+			counts[scheme] = len(report.Schemes)
+			report.Schemes = append(report.Schemes, PasswordHashCount{Scheme: scheme, Legacy: scheme != current, Count: count})
+		}
+
+		report.Total += count
+		if scheme != current {
+			report.Legacy += count
+		}
+	}
+
+	return report, rows.Err()
+}
@@ -0,0 +1,58 @@
+package store
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordHashing(t *testing.T) {
+	bcryptHashing := PasswordHashing{Algorithm: HashBcrypt, BcryptCost: bcrypt.MinCost}
+	argon2Hashing := PasswordHashing{
+		Algorithm: HashArgon2id,
+		Argon2:    Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+	}
+
+	for _, hashing := range []PasswordHashing{bcryptHashing, argon2Hashing} {
+		t.Run(hashing.Algorithm, func(t *testing.T) {
+			var p password
+			if err := p.Set("correct horse", hashing); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := p.Compare("correct horse"); err != nil {
+				t.Errorf("expected the password to match, got %v", err)
+			}
+
+			if err := p.Compare("wrong horse"); err == nil {
+				t.Error("expected a wrong password not to match")
+			}
+
+			if p.NeedsRehash(hashing) {
+				t.Error("expected a fresh hash not to need a rehash")
+			}
+		})
+	}
+
+	t.Run("should ask to rehash on algorithm or cost changes", func(t *testing.T) {
+		var p password
+		if err := p.Set("correct horse", bcryptHashing); err != nil {
+			t.Fatal(err)
+		}
+
+		costlier := bcryptHashing
+		costlier.BcryptCost++
+		if !p.NeedsRehash(costlier) {
+			t.Error("expected a lower bcrypt cost to need a rehash")
+		}
+
+		if !p.NeedsRehash(argon2Hashing) {
+			t.Error("expected a bcrypt hash to need a rehash under argon2id")
+		}
+
+		// bcrypt hashes still verify after switching algorithms
+		if err := p.Compare("correct horse"); err != nil {
+			t.Errorf("expected the password to match, got %v", err)
+		}
+	})
+}
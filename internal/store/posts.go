@@ -139,13 +139,21 @@ func (s *PostsStore) GetUserFeed(ctx context.Context, userID int64, fq Paginated
 			u.username,
 			COUNT(c.id) AS comments_count
 		FROM posts p
-		INNER JOIN followers f ON f.follower_id = p.user_id OR p.user_id = $1
 		LEFT JOIN comments c ON c.post_id  = p.id
+			AND NOT EXISTS (SELECT 1 FROM user_mutes m WHERE m.muter_id = $1 AND m.muted_id = c.user_id)
 		LEFT JOIN users u ON u.id = p.user_id
 		WHERE 
-			(f.user_id = $1 OR p.user_id = $1)
+			-- only approved followers see the posts of private accounts, and
+			-- blocks remove the follows
+			(p.user_id = $1 OR EXISTS (
+				SELECT 1 FROM followers f
+				WHERE f.user_id = p.user_id AND f.follower_id = $1
+			))
+			AND NOT EXISTS (SELECT 1 FROM user_mutes m WHERE m.muter_id = $1 AND m.muted_id = p.user_id)
 			AND (p.title ILIKE '%' || $4 || '%' OR p.content ILIKE '%' || $4 || '%')
 			AND (p.tags @> $5 OR $5 = '{}')
+			AND ($6::timestamptz IS NULL OR p.created_at >= $6)
+			AND ($7::timestamptz IS NULL OR p.created_at <= $7)
 		GROUP BY p.id, u.username
 		ORDER BY p.created_at ` + fq.Sort + `
 		LIMIT $2
@@ -155,7 +163,7 @@ func (s *PostsStore) GetUserFeed(ctx context.Context, userID int64, fq Paginated
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query, userID, fq.Limit, fq.Offset, fq.Term, pq.Array(fq.Tags))
+	rows, err := s.db.QueryContext(ctx, query, userID, fq.Limit, fq.Offset, fq.Term, pq.Array(fq.Tags), nullString(fq.Since), nullString(fq.Until))
 	if err != nil {
 		return nil, err
 	}
@@ -185,5 +193,10 @@ func (s *PostsStore) GetUserFeed(ctx context.Context, userID int64, fq Paginated
 		feed = append(feed, post)
 	}
 
-	return feed, nil
+	return feed, rows.Err()
+}
+
+// nullString passes empty strings to queries as NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
 }
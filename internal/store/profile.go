@@ -0,0 +1,173 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"time"
+)
+
+// EmailChange is a request to move an account to NewEmail. Token is the
+// hash of the token sent to the new address in Confirmation, and Notice
+// tells the current address about the request.
+type EmailChange struct {
+	NewEmail     string
+	Token        string
+	Exp          time.Duration
+	Confirmation *OutboxEmail
+	Notice       *OutboxEmail
+}
+
+// UpdateProfile saves the username, the profile fields and the privacy of
+// the user, and stores the email change if one is given. Nothing is saved
+// when the new email is taken. Pending follow requests are approved when the
+// account becomes public, and the requesters are returned.
+func (s *UsersStore) UpdateProfile(ctx context.Context, user *User, change *EmailChange) ([]int64, error) {
+	var requesterIDs []int64
+
+	err := withTransaction(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		if change != nil {
+			if err := s.requestEmailChange(ctx, tx, user.ID, change); err != nil {
+				return err
+			}
+		}
+
+		query := `
+			UPDATE users
+			SET username = $1, display_name = $2, bio = $3, avatar_url = $4, location = $5, is_private = $6
+			WHERE id = $7
+		`
+
+		res, err := tx.ExecContext(ctx, query, user.Username, user.DisplayName, user.Bio, user.AvatarURL, user.Location, user.IsPrivate, user.ID)
+		if err != nil {
+			switch {
+			case err.Error() == `pq: duplicate key value violates unique constraint "users_username_key"`:
+				return ErrDuplicateUsername
+			default:
+				return err
+			}
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		if user.IsPrivate {
+			return nil
+		}
+
+		requesterIDs, err = approveAllRequests(ctx, tx, user.ID)
+		return err
+	})
+
+	return requesterIDs, err
+}
+
+// requestEmailChange stores a pending email change and queues its emails.
+// Earlier pending changes of the user are discarded.
+func (s *UsersStore) requestEmailChange(ctx context.Context, tx *sql.Tx, userID int64, change *EmailChange) error {
+	var taken bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE email = $1)`, change.NewEmail).Scan(&taken); err != nil {
+		return err
+	}
+
+	if taken {
+		return ErrDuplicateEmail
+	}
+
+	if err := s.deleteEmailChanges(ctx, tx, userID); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO email_changes (token, user_id, new_email, expiry)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	if _, err := tx.ExecContext(ctx, query, change.Token, userID, change.NewEmail, time.Now().Add(change.Exp)); err != nil {
+		return err
+	}
+
+	if err := enqueueEmail(ctx, tx, change.Confirmation); err != nil {
+		return err
+	}
+
+	return enqueueEmail(ctx, tx, change.Notice)
+}
+
+// ConfirmEmailChange sets the email of the user to the one of the pending
+// change identified by token and returns the user.
+func (s *UsersStore) ConfirmEmailChange(ctx context.Context, token string) (*User, error) {
+	user := &User{}
+
+	err := withTransaction(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		hash := sha256.Sum256([]byte(token))
+		hashToken := hex.EncodeToString(hash[:])
+
+		query := `
+			SELECT user_id, new_email FROM email_changes
+			WHERE token = $1 AND expiry > $2
+		`
+
+		if err := tx.QueryRowContext(ctx, query, hashToken, time.Now()).Scan(&user.ID, &user.Email); err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE users SET email = $1 WHERE id = $2`, user.Email, user.ID); err != nil {
+			switch {
+			case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
+				return ErrDuplicateEmail
+			default:
+				return err
+			}
+		}
+
+		return s.deleteEmailChanges(ctx, tx, user.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *UsersStore) DeleteExpiredEmailChanges(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `DELETE FROM email_changes WHERE expiry < NOW()`
+
+	res, err := s.db.ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func (s *UsersStore) deleteEmailChanges(ctx context.Context, tx *sql.Tx, userID int64) error {
+	query := `DELETE FROM email_changes WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userID)
+	return err
+}
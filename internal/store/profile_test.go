@@ -0,0 +1,91 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestUpdateProfileWithEmailChange(t *testing.T) {
+	ctx := context.Background()
+	user := &User{ID: 7, Username: "ana", IsPrivate: true}
+
+	change := &EmailChange{
+		NewEmail:     "new@example.com",
+		Token:        "hash",
+		Exp:          time.Hour,
+		Confirmation: &OutboxEmail{Template: "email_change.templ", Email: "new@example.com"},
+		Notice:       &OutboxEmail{Template: "email_change_notice.templ", Email: "ana@example.com"},
+	}
+
+	t.Run("should not update the profile when the email is taken", func(t *testing.T) {
+		storage, mock := newTestDB(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM users WHERE email = \$1\)`).
+			WithArgs("new@example.com").
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectRollback()
+
+		if _, err := storage.Users.UpdateProfile(ctx, user, change); !errors.Is(err, ErrDuplicateEmail) {
+			t.Errorf("expected ErrDuplicateEmail, got %v", err)
+		}
+	})
+
+	t.Run("should store the change and both emails with the profile", func(t *testing.T) {
+		storage, mock := newTestDB(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT EXISTS`).
+			WithArgs("new@example.com").
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectExec(`DELETE FROM email_changes WHERE user_id = \$1`).
+			WithArgs(int64(7)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`INSERT INTO email_changes`).
+			WithArgs("hash", int64(7), "new@example.com", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(`INSERT INTO email_outbox`).
+			WithArgs("email_change.templ", sqlmock.AnyArg(), "new@example.com", sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, "2026-01-01"))
+		mock.ExpectQuery(`INSERT INTO email_outbox`).
+			WithArgs("email_change_notice.templ", sqlmock.AnyArg(), "ana@example.com", sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(2, "2026-01-01"))
+		mock.ExpectExec(`UPDATE users\s+SET username = \$1`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		if _, err := storage.Users.UpdateProfile(ctx, user, change); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestUpdateProfileGoingPublic(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should approve the pending requests and return the requesters", func(t *testing.T) {
+		storage, mock := newTestDB(t)
+		user := &User{ID: 7, Username: "ana"}
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE users\s+SET username = \$1`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(`DELETE FROM follow_requests WHERE user_id = \$1\s+RETURNING requester_id`).
+			WithArgs(int64(7)).
+			WillReturnRows(sqlmock.NewRows([]string{"follower_id"}).AddRow(int64(2)).AddRow(int64(3)))
+		mock.ExpectCommit()
+
+		requesterIDs, err := storage.Users.UpdateProfile(ctx, user, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(requesterIDs) != 2 || requesterIDs[0] != 2 || requesterIDs[1] != 3 {
+			t.Errorf("expected requesters [2 3], got %v", requesterIDs)
+		}
+	})
+}
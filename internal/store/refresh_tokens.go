@@ -0,0 +1,205 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrTokenReused = errors.New("refresh token has already been used")
+
+type RefreshToken struct {
+	Token    string
+	UserID   int64
+	FamilyID string
+	Expiry   time.Time
+}
+
+type RefreshTokensStore struct {
+	db *sql.DB
+}
+
+func (s *RefreshTokensStore) create(ctx context.Context, tx *sql.Tx, token *RefreshToken) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+		INSERT INTO refresh_tokens (token, user_id, family_id, expiry)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	_, err := tx.ExecContext(ctx, query, token.Token, token.UserID, token.FamilyID, token.Expiry)
+	return err
+}
+
+// Rotate consumes the refresh token and stores next in the same family.
+// Presenting a token that was already consumed revokes the whole family
+// and returns ErrTokenReused.
+func (s *RefreshTokensStore) Rotate(ctx context.Context, token string, next *RefreshToken) error {
+	reused := false
+
+	err := withTransaction(s.db, ctx, func(tx *sql.Tx) error {
+		current, usedAt, revokedAt, err := s.getForUpdate(ctx, tx, token)
+		if err != nil {
+			return err
+		}
+
+		if usedAt.Valid {
+			reused = true
+			return s.revokeFamily(ctx, tx, current.FamilyID)
+		}
+
+		if revokedAt.Valid || current.Expiry.Before(time.Now()) {
+			return ErrNotFound
+		}
+
+		if err := s.markUsed(ctx, tx, token); err != nil {
+			return err
+		}
+
+		next.UserID = current.UserID
+		next.FamilyID = current.FamilyID
+
+		if err := s.create(ctx, tx, next); err != nil {
+			return err
+		}
+
+		return s.touchSession(ctx, tx, next)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if reused {
+		return ErrTokenReused
+	}
+
+	return nil
+}
+
+func (s *RefreshTokensStore) getForUpdate(ctx context.Context, tx *sql.Tx, token string) (*RefreshToken, sql.NullTime, sql.NullTime, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+		SELECT token, user_id, family_id, expiry, used_at, revoked_at
+		FROM refresh_tokens
+		WHERE token = $1
+		FOR UPDATE
+	`
+
+	rt := &RefreshToken{}
+	var usedAt, revokedAt sql.NullTime
+
+	err := tx.QueryRowContext(ctx, query, token).Scan(
+		&rt.Token,
+		&rt.UserID,
+		&rt.FamilyID,
+		&rt.Expiry,
+		&usedAt,
+		&revokedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, usedAt, revokedAt, ErrNotFound
+		default:
+			return nil, usedAt, revokedAt, err
+		}
+	}
+
+	return rt, usedAt, revokedAt, nil
+}
+
+// touchSession records that the session of the family was just used and
+// extends it to the expiry of its newest refresh token.
+func (s *RefreshTokensStore) touchSession(ctx context.Context, tx *sql.Tx, token *RefreshToken) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `UPDATE sessions SET last_seen_at = NOW(), expiry = $2 WHERE id = $1`
+
+	_, err := tx.ExecContext(ctx, query, token.FamilyID, token.Expiry)
+	return err
+}
+
+func (s *RefreshTokensStore) markUsed(ctx context.Context, tx *sql.Tx, token string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `UPDATE refresh_tokens SET used_at = NOW() WHERE token = $1`
+
+	_, err := tx.ExecContext(ctx, query, token)
+	return err
+}
+
+func (s *RefreshTokensStore) RevokeFamily(ctx context.Context, familyID string) error {
+	return withTransaction(s.db, ctx, func(tx *sql.Tx) error {
+		return s.revokeFamily(ctx, tx, familyID)
+	})
+}
+
+func (s *RefreshTokensStore) revokeFamily(ctx context.Context, tx *sql.Tx, familyID string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+		UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE family_id = $1 AND revoked_at IS NULL
+	`
+
+	if _, err := tx.ExecContext(ctx, query, familyID); err != nil {
+		return err
+	}
+
+	query = `UPDATE sessions SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL`
+
+	_, err := tx.ExecContext(ctx, query, familyID)
+	return err
+}
+
+// RevokeByUser revokes every refresh token and session of the user and
+// returns the ids of the sessions it revoked.
+func (s *RefreshTokensStore) RevokeByUser(ctx context.Context, userID int64) ([]string, error) {
+	var sessionIDs []string
+
+	err := withTransaction(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `
+			UPDATE refresh_tokens SET revoked_at = NOW()
+			WHERE user_id = $1 AND revoked_at IS NULL
+		`
+
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			return err
+		}
+
+		query = `
+			UPDATE sessions SET revoked_at = NOW()
+			WHERE user_id = $1 AND revoked_at IS NULL
+			RETURNING id
+		`
+
+		rows, err := tx.QueryContext(ctx, query, userID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				return err
+			}
+			sessionIDs = append(sessionIDs, id)
+		}
+
+		return rows.Err()
+	})
+
+	return sessionIDs, err
+}
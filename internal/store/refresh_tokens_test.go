@@ -0,0 +1,94 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestRotateRefreshToken(t *testing.T) {
+	ctx := context.Background()
+	columns := []string{"token", "user_id", "family_id", "expiry", "used_at", "revoked_at"}
+
+	t.Run("should consume the token and store the next one", func(t *testing.T) {
+		storage, mock := newTestDB(t)
+		next := &RefreshToken{Token: "next", Expiry: time.Now().Add(time.Hour)}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT .+ FROM refresh_tokens WHERE token = \$1 FOR UPDATE`).
+			WithArgs("current").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow("current", 7, "family", time.Now().Add(time.Hour), nil, nil))
+		mock.ExpectExec(`UPDATE refresh_tokens SET used_at = NOW\(\) WHERE token = \$1`).
+			WithArgs("current").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`INSERT INTO refresh_tokens`).
+			WithArgs("next", 7, "family", next.Expiry).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`UPDATE sessions SET last_seen_at = NOW\(\)`).
+			WithArgs("family", next.Expiry).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		if err := storage.RefreshTokens.Rotate(ctx, "current", next); err != nil {
+			t.Fatal(err)
+		}
+
+		if next.UserID != 7 || next.FamilyID != "family" {
+			t.Errorf("expected the next token to join family of user 7, got %q of user %d", next.FamilyID, next.UserID)
+		}
+	})
+
+	t.Run("should revoke the family when a used token is replayed", func(t *testing.T) {
+		storage, mock := newTestDB(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT .+ FROM refresh_tokens`).
+			WithArgs("current").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow("current", 7, "family", time.Now().Add(time.Hour), time.Now(), nil))
+		mock.ExpectExec(`UPDATE refresh_tokens SET revoked_at = NOW\(\)\s+WHERE family_id = \$1`).
+			WithArgs("family").
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec(`UPDATE sessions SET revoked_at = NOW\(\) WHERE id = \$1`).
+			WithArgs("family").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := storage.RefreshTokens.Rotate(ctx, "current", &RefreshToken{Token: "next"})
+		if !errors.Is(err, ErrTokenReused) {
+			t.Errorf("expected ErrTokenReused, got %v", err)
+		}
+	})
+
+	t.Run("should reject an expired token", func(t *testing.T) {
+		storage, mock := newTestDB(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT .+ FROM refresh_tokens`).
+			WithArgs("current").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow("current", 7, "family", time.Now().Add(-time.Minute), nil, nil))
+		mock.ExpectRollback()
+
+		err := storage.RefreshTokens.Rotate(ctx, "current", &RefreshToken{Token: "next"})
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("should reject a revoked token", func(t *testing.T) {
+		storage, mock := newTestDB(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT .+ FROM refresh_tokens`).
+			WithArgs("current").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow("current", 7, "family", time.Now().Add(time.Hour), nil, time.Now()))
+		mock.ExpectRollback()
+
+		err := storage.RefreshTokens.Rotate(ctx, "current", &RefreshToken{Token: "next"})
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,44 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestRevokeUser(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should store the revocation truncated to the second", func(t *testing.T) {
+		storage, mock := newTestDB(t)
+
+		mock.ExpectExec(`INSERT INTO user_token_revocations \(user_id, revoked_at\) VALUES \(\$1, date_trunc\('second', clock_timestamp\(\)\)\)`).
+			WithArgs(int64(7)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		if err := storage.RevokedTokens.RevokeUser(ctx, 7, time.Now().Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("should accept a login in the same second as the revocation", func(t *testing.T) {
+		storage, mock := newTestDB(t)
+		issuedAt := time.Now().Truncate(time.Second)
+
+		// the stored revocation is never rounded past the second of the login
+		mock.ExpectQuery(`user_token_revocations WHERE user_id = \$2 AND revoked_at > \$3\)`).
+			WithArgs("jti", int64(7), issuedAt, "").
+			WillReturnRows(sqlmock.NewRows([]string{"revoked"}).AddRow(false))
+
+		revoked, err := storage.RevokedTokens.IsRevoked(ctx, "jti", "", 7, issuedAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if revoked {
+			t.Error("expected a token issued in the second of the revocation to be accepted")
+		}
+	})
+}
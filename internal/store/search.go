@@ -0,0 +1,65 @@
+package store
+
+import (
+	"context"
+	"strings"
+)
+
+// UserSearchResult is a user found by Search.
+type UserSearchResult struct {
+	ID             int64  `json:"id"`
+	Username       string `json:"username"`
+	DisplayName    string `json:"display_name"`
+	AvatarURL      string `json:"avatar_url"`
+	IsPrivate      bool   `json:"is_private"`
+	FollowersCount int64  `json:"followers_count"`
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Search finds active users whose username or display name starts with term
+// or is similar to it. Prefix matches come first, then the closest ones.
+// Users who blocked viewerID, or whom viewerID blocked, are left out.
+func (s *UsersStore) Search(ctx context.Context, viewerID int64, term string, pq PaginatedQuery) ([]UserSearchResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+		SELECT u.id, u.username, u.display_name, u.avatar_url, u.is_private, u.followers_count
+		FROM users u
+		WHERE u.is_active
+			AND (
+				u.username ILIKE $3 || '%' OR u.display_name ILIKE $3 || '%'
+				OR u.username % $2 OR u.display_name % $2
+			)
+			AND NOT EXISTS (
+				SELECT 1 FROM user_blocks b
+				WHERE (b.blocker_id = $1 AND b.blocked_id = u.id) OR (b.blocker_id = u.id AND b.blocked_id = $1)
+			)
+		ORDER BY
+			(u.username ILIKE $3 || '%' OR u.display_name ILIKE $3 || '%') DESC,
+			GREATEST(similarity(u.username, $2), similarity(u.display_name, $2)) DESC,
+			u.followers_count DESC,
+			u.id
+		LIMIT $4 OFFSET $5
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, viewerID, term, likeEscaper.Replace(term), pq.Limit, pq.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	users := []UserSearchResult{}
+
+	for rows.Next() {
+		var u UserSearchResult
+		if err := rows.Scan(&u.ID, &u.Username, &u.DisplayName, &u.AvatarURL, &u.IsPrivate, &u.FollowersCount); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
@@ -0,0 +1,218 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Session is a login of a user on a device. Its id is the family id of the
+// refresh tokens issued for it and the sid claim of its access tokens.
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     int64     `json:"user_id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  string    `json:"created_at"`
+	LastSeenAt string    `json:"last_seen_at"`
+	Expiry     time.Time `json:"expires_at"`
+}
+
+type SessionsStore struct {
+	db *sql.DB
+}
+
+// Create records the session together with its first refresh token.
+func (s *SessionsStore) Create(ctx context.Context, session *Session, token *RefreshToken) error {
+	refreshTokens := &RefreshTokensStore{s.db}
+
+	return withTransaction(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `
+			INSERT INTO sessions (id, user_id, user_agent, ip, expiry)
+			VALUES ($1, $2, $3, $4, $5) RETURNING created_at, last_seen_at
+		`
+
+		if err := tx.QueryRowContext(ctx, query, session.ID, session.UserID, session.UserAgent, session.IP, session.Expiry).Scan(
+			&session.CreatedAt,
+			&session.LastSeenAt,
+		); err != nil {
+			return err
+		}
+
+		return refreshTokens.create(ctx, tx, token)
+	})
+}
+
+// GetByUserID returns the sessions of the user that are neither revoked nor
+// expired, most recently seen first.
+func (s *SessionsStore) GetByUserID(ctx context.Context, userID int64) ([]Session, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+		SELECT id, user_id, user_agent, ip, created_at, last_seen_at, expiry
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expiry > NOW()
+		ORDER BY last_seen_at DESC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	sessions := []Session{}
+
+	for rows.Next() {
+		session := Session{}
+		if err := rows.Scan(
+			&session.ID,
+			&session.UserID,
+			&session.UserAgent,
+			&session.IP,
+			&session.CreatedAt,
+			&session.LastSeenAt,
+			&session.Expiry,
+		); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// Revoke ends a session of the user and revokes its refresh tokens.
+func (s *SessionsStore) Revoke(ctx context.Context, sessionID string, userID int64) error {
+	refreshTokens := &RefreshTokensStore{s.db}
+
+	return withTransaction(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `
+			SELECT 1 FROM sessions
+			WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+		`
+
+		var found int
+		if err := tx.QueryRowContext(ctx, query, sessionID, userID).Scan(&found); err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		return refreshTokens.revokeFamily(ctx, tx, sessionID)
+	})
+}
+
+// RevokeOthers ends every active session of the user except
+// currentSessionID and returns the ids of the sessions it ended.
+func (s *SessionsStore) RevokeOthers(ctx context.Context, userID int64, currentSessionID string) ([]string, error) {
+	refreshTokens := &RefreshTokensStore{s.db}
+	revoked := []string{}
+
+	err := withTransaction(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `
+			SELECT id FROM sessions
+			WHERE user_id = $1 AND id::text <> $2 AND revoked_at IS NULL AND expiry > NOW()
+			FOR UPDATE
+		`
+
+		rows, err := tx.QueryContext(ctx, query, userID, currentSessionID)
+		if err != nil {
+			return err
+		}
+
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			revoked = append(revoked, id)
+		}
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, id := range revoked {
+			if err := refreshTokens.revokeFamily(ctx, tx, id); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return revoked, nil
+}
+
+// Touch records that the session was just used. Sessions seen in the last
+// minute are left as they are, so busy clients do not write on every
+// request.
+func (s *SessionsStore) Touch(ctx context.Context, sessionID string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+		UPDATE sessions SET last_seen_at = NOW()
+		WHERE id = $1 AND revoked_at IS NULL AND last_seen_at < NOW() - INTERVAL '1 minute'
+	`
+
+	_, err := s.db.ExecContext(ctx, query, sessionID)
+	return err
+}
+
+// DeleteExpired purges the expired sessions and the ones revoked before
+// revokedBefore, together with their refresh tokens. Revoked sessions are
+// kept until the access tokens issued for them expire, since revocation
+// checks read them.
+func (s *SessionsStore) DeleteExpired(ctx context.Context, revokedBefore time.Time) (int64, error) {
+	var deleted int64
+
+	err := withTransaction(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `
+			DELETE FROM refresh_tokens
+			WHERE expiry < NOW() OR revoked_at < $1
+		`
+
+		if _, err := tx.ExecContext(ctx, query, revokedBefore); err != nil {
+			return err
+		}
+
+		query = `
+			DELETE FROM sessions
+			WHERE expiry < NOW() OR revoked_at < $1
+		`
+
+		res, err := tx.ExecContext(ctx, query, revokedBefore)
+		if err != nil {
+			return err
+		}
+
+		deleted, err = res.RowsAffected()
+		return err
+	})
+
+	return deleted, err
+}
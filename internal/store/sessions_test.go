@@ -0,0 +1,44 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestTouchSession(t *testing.T) {
+	storage, mock := newTestDB(t)
+
+	mock.ExpectExec(`UPDATE sessions SET last_seen_at = NOW\(\)\s+WHERE id = \$1 AND revoked_at IS NULL AND last_seen_at < NOW\(\) - INTERVAL '1 minute'`).
+		WithArgs("session").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := storage.Sessions.Touch(context.Background(), "session"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeleteExpiredSessions(t *testing.T) {
+	storage, mock := newTestDB(t)
+	revokedBefore := time.Now().Add(-time.Hour)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM refresh_tokens\s+WHERE expiry < NOW\(\) OR revoked_at < \$1`).
+		WithArgs(revokedBefore).
+		WillReturnResult(sqlmock.NewResult(0, 4))
+	mock.ExpectExec(`DELETE FROM sessions\s+WHERE expiry < NOW\(\) OR revoked_at < \$1`).
+		WithArgs(revokedBefore).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	deleted, err := storage.Sessions.DeleteExpired(context.Background(), revokedBefore)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if deleted != 2 {
+		t.Errorf("expected 2 sessions to be deleted, got %d", deleted)
+	}
+}
@@ -25,35 +25,152 @@ type Storage struct {
 	Users interface {
 		Create(ctx context.Context, tx *sql.Tx, user *User) error
 		GetByID(ctx context.Context, userID int64) (*User, error)
-		CreateAndInvite(ctx context.Context, user *User, token string, invitationExp time.Duration) error
+		CreateAndInvite(ctx context.Context, user *User, token string, invitationExp time.Duration, email *OutboxEmail) error
 		Activate(ctx context.Context, token string) error
 		Delete(ctx context.Context, userID int64) error
 		GetByEmail(ctx context.Context, Email string) (*User, error)
+		CreatePasswordReset(ctx context.Context, userID int64, token string, exp time.Duration, email *OutboxEmail) error
+		ResetPassword(ctx context.Context, token string, user *User) error
+		ChangePassword(ctx context.Context, user *User, email *OutboxEmail) error
+		RehashPassword(ctx context.Context, user *User, text string) error
+		PasswordHashReport(ctx context.Context) (*PasswordHashReport, error)
+		SetTOTPSecret(ctx context.Context, userID int64, secret string) error
+		GetTOTPSecret(ctx context.Context, userID int64) (string, error)
+		EnableTOTP(ctx context.Context, userID int64, recoveryCodes []string) error
+		DisableTOTP(ctx context.Context, userID int64) error
+		UseRecoveryCode(ctx context.Context, userID int64, code string) error
+		UseTOTPStep(ctx context.Context, userID int64, step int64) error
+		GetPendingActivation(ctx context.Context, email string) (*User, error)
+		RotateInvitation(ctx context.Context, userID int64, token string, invitationExp time.Duration, email *OutboxEmail) error
+		DeleteExpiredInvitations(ctx context.Context) (int64, error)
+		DeleteUnactivated(ctx context.Context, gracePeriod time.Duration) (int64, error)
+		UpdateProfile(ctx context.Context, user *User, change *EmailChange) ([]int64, error)
+		ConfirmEmailChange(ctx context.Context, token string) (*User, error)
+		DeleteExpiredEmailChanges(ctx context.Context) (int64, error)
+		Search(ctx context.Context, viewerID int64, term string, pq PaginatedQuery) ([]UserSearchResult, error)
+		ScheduleDeletion(ctx context.Context, userID int64, at time.Time, email *OutboxEmail) error
+		CancelDeletion(ctx context.Context, userID int64) error
+		GetDueDeletions(ctx context.Context, limit int) ([]int64, error)
+		DeleteScheduled(ctx context.Context, userID int64) error
 	}
 
 	Comments interface {
 		Create(ctx context.Context, comment *Comment) error
-		GetByPostID(ctx context.Context, postID int64) ([]Comment, error)
+		GetByPostID(ctx context.Context, postID int64, viewerID int64) ([]Comment, error)
 	}
 	Followers interface {
-		Follow(ctx context.Context, followerID int64, userID int64) error
+		Follow(ctx context.Context, followerID int64, userID int64) (bool, error)
 		Unfollow(ctx context.Context, followerID int64, userID int64) error
+		CanView(ctx context.Context, viewerID int64, userID int64) (bool, error)
+		GetRequests(ctx context.Context, userID int64, pq PaginatedQuery) ([]FollowRequest, error)
+		ApproveRequest(ctx context.Context, userID int64, requesterID int64) error
+		RejectRequest(ctx context.Context, userID int64, requesterID int64) error
+		GetFollowers(ctx context.Context, userID int64, pq PaginatedQuery) ([]FollowListEntry, error)
+		GetFollowing(ctx context.Context, userID int64, pq PaginatedQuery) ([]FollowListEntry, error)
+	}
+	Suggestions interface {
+		Get(ctx context.Context, userID int64) ([]Suggestion, error)
+	}
+	Blocks interface {
+		Block(ctx context.Context, blockerID int64, blockedID int64) error
+		Unblock(ctx context.Context, blockerID int64, blockedID int64) error
+		ExistsBetween(ctx context.Context, userID int64, otherID int64) (bool, error)
+		GetBlocked(ctx context.Context, blockerID int64, pq PaginatedQuery) ([]RelatedUser, error)
+	}
+	Mutes interface {
+		Mute(ctx context.Context, muterID int64, mutedID int64) error
+		Unmute(ctx context.Context, muterID int64, mutedID int64) error
+		GetMuted(ctx context.Context, muterID int64, pq PaginatedQuery) ([]RelatedUser, error)
 	}
 	Roles interface {
 		GetByName(ctx context.Context, roleName string) (*Role, error)
 	}
+	RefreshTokens interface {
+		Rotate(ctx context.Context, token string, next *RefreshToken) error
+		RevokeFamily(ctx context.Context, familyID string) error
+		RevokeByUser(ctx context.Context, userID int64) ([]string, error)
+	}
+	RevokedTokens interface {
+		Revoke(ctx context.Context, jti string, exp time.Time) error
+		RevokeUser(ctx context.Context, userID int64, exp time.Time) error
+		RevokeSession(ctx context.Context, sessionID string, exp time.Time) error
+		IsRevoked(ctx context.Context, jti string, sessionID string, userID int64, issuedAt time.Time) (bool, error)
+	}
+	AccessTokens interface {
+		Create(ctx context.Context, token *AccessToken) error
+		GetByUserID(ctx context.Context, userID int64) ([]AccessToken, error)
+		Authenticate(ctx context.Context, token string) (*AccessToken, error)
+		Delete(ctx context.Context, tokenID int64, userID int64) error
+		DeleteByUser(ctx context.Context, userID int64) error
+	}
+	LoginAttempts interface {
+		LockedFor(ctx context.Context, key string) (time.Duration, error)
+		RegisterFailure(ctx context.Context, key string, maxAttempts int, window, lockout time.Duration) (bool, error)
+		Reset(ctx context.Context, key string) error
+		DeleteExpired(ctx context.Context, window time.Duration) (int64, error)
+	}
+	Outbox interface {
+		Enqueue(ctx context.Context, email *OutboxEmail) error
+		ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]OutboxEmail, error)
+		MarkSent(ctx context.Context, id int64) error
+		MarkFailed(ctx context.Context, id int64, lastError string, nextAttempt time.Time, dead bool) error
+		DeleteOld(ctx context.Context, retention time.Duration) (int64, error)
+	}
+	Exports interface {
+		Create(ctx context.Context, userID int64) (*DataExport, error)
+		ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]DataExport, error)
+		Complete(ctx context.Context, exportID int64, token string, archive []byte, expiry time.Time, email *OutboxEmail) error
+		Fail(ctx context.Context, exportID int64) error
+		GetArchive(ctx context.Context, exportID int64, token string) ([]byte, error)
+		DeleteExpired(ctx context.Context) (int64, error)
+		CollectUserData(ctx context.Context, userID int64) (*UserData, error)
+	}
+	Identities interface {
+		GetUserID(ctx context.Context, provider, subject string) (int64, error)
+		LinkOrCreate(ctx context.Context, identity *Identity, user *User) error
+	}
+	MagicLinks interface {
+		Create(ctx context.Context, userID int64, token string, exp time.Duration, email *OutboxEmail) error
+		Consume(ctx context.Context, token string) (*User, error)
+		DeleteExpired(ctx context.Context) (int64, error)
+	}
+	Sessions interface {
+		Create(ctx context.Context, session *Session, token *RefreshToken) error
+		GetByUserID(ctx context.Context, userID int64) ([]Session, error)
+		Revoke(ctx context.Context, sessionID string, userID int64) error
+		RevokeOthers(ctx context.Context, userID int64, currentSessionID string) ([]string, error)
+		Touch(ctx context.Context, sessionID string) error
+		DeleteExpired(ctx context.Context, revokedBefore time.Time) (int64, error)
+	}
 }
 
-func NewStorage(db *sql.DB) Storage {
+func NewStorage(db *sql.DB, hashing PasswordHashing) Storage {
 	return Storage{
-		Posts:     &PostsStore{db},
-		Users:     &UsersStore{db},
-		Comments:  &CommentsStore{db},
-		Followers: &FollowersStore{db},
-		Roles:     &RolesStore{db},
+		Posts:         &PostsStore{db},
+		Users:         &UsersStore{db, hashing},
+		Comments:      &CommentsStore{db},
+		Followers:     &FollowersStore{db},
+		Suggestions:   &SuggestionsStore{db},
+		Blocks:        &BlocksStore{db},
+		Mutes:         &MutesStore{db},
+		Roles:         &RolesStore{db},
+		RefreshTokens: &RefreshTokensStore{db},
+		RevokedTokens: &RevokedTokensStore{db},
+		AccessTokens:  &AccessTokensStore{db},
+		LoginAttempts: &LoginAttemptsStore{db},
+		Outbox:        &OutboxStore{db},
+		Exports:       &ExportsStore{db},
+		Identities:    &IdentitiesStore{db},
+		MagicLinks:    &MagicLinksStore{db},
+		Sessions:      &SessionsStore{db},
 	}
 }
 
+// queryRower is a *sql.DB or a *sql.Tx.
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
 func withTransaction(db *sql.DB, ctx context.Context, fn func(*sql.Tx) error) error {
 	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
@@ -0,0 +1,28 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// newTestDB returns a database whose queries must match the expectations
+// set on the returned mock, in order.
+func newTestDB(t *testing.T) (*Storage, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		db.Close()
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	storage := NewStorage(db, DefaultPasswordHashing())
+	return &storage, mock
+}
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+// MaxSuggestions is how many suggestions Get returns at most.
+const MaxSuggestions = 50
+
+type SuggestionsStore struct {
+	db *sql.DB
+}
+
+// Suggestion is a user worth following, with the reasons it was suggested.
+type Suggestion struct {
+	ID             int64  `json:"id"`
+	Username       string `json:"username"`
+	DisplayName    string `json:"display_name"`
+	AvatarURL      string `json:"avatar_url"`
+	FollowersCount int64  `json:"followers_count"`
+	// MutualFollows is how many of the users userID follows follow them
+	MutualFollows int64 `json:"mutual_follows"`
+	// SharedTags is how many tags of userID's posts they also used
+	SharedTags int64 `json:"shared_tags"`
+}
+
+// Get ranks the users userID may want to follow. Users followed by the ones
+// userID follows weigh the most, then those posting with the same tags, and
+// the most followed users fill in for new accounts with neither. Users
+// userID follows, asked to follow or is blocked with are left out.
+func (s *SuggestionsStore) Get(ctx context.Context, userID int64) ([]Suggestion, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+		WITH following AS (
+			SELECT user_id FROM followers WHERE follower_id = $1
+		),
+		mutual AS (
+			SELECT f.user_id AS id, COUNT(*) AS count
+			FROM followers f
+			INNER JOIN following fo ON fo.user_id = f.follower_id
+			GROUP BY f.user_id
+		),
+		own_tags AS (
+			SELECT COALESCE(array_agg(DISTINCT tag), '{}')::varchar[] AS tags
+			FROM posts, unnest(tags) AS tag WHERE user_id = $1
+		),
+		shared AS (
+			-- && on the whole array lets idx_posts_tags find the posts
+			SELECT p.user_id AS id, COUNT(DISTINCT t.tag) AS count
+			FROM posts p, unnest(p.tags) AS t(tag)
+			WHERE p.tags && (SELECT tags FROM own_tags)
+				AND t.tag = ANY ((SELECT tags FROM own_tags))
+			GROUP BY p.user_id
+		),
+		candidates AS (
+			SELECT id FROM mutual
+			UNION SELECT id FROM shared
+			UNION (SELECT id FROM users WHERE is_active ORDER BY followers_count DESC LIMIT $2)
+		)
+		SELECT u.id, u.username, u.display_name, u.avatar_url, u.followers_count,
+			COALESCE(m.count, 0), COALESCE(s.count, 0)
+		FROM candidates c
+		INNER JOIN users u ON u.id = c.id
+		LEFT JOIN mutual m ON m.id = u.id
+		LEFT JOIN shared s ON s.id = u.id
+		WHERE u.is_active
+			AND u.id <> $1
+			AND NOT EXISTS (SELECT 1 FROM following fo WHERE fo.user_id = u.id)
+			AND NOT EXISTS (SELECT 1 FROM follow_requests fr WHERE fr.requester_id = $1 AND fr.user_id = u.id)
+			AND NOT EXISTS (
+				SELECT 1 FROM user_blocks b
+				WHERE (b.blocker_id = $1 AND b.blocked_id = u.id) OR (b.blocker_id = u.id AND b.blocked_id = $1)
+			)
+		ORDER BY 3 * COALESCE(m.count, 0) + 2 * COALESCE(s.count, 0) DESC, u.followers_count DESC, u.id
+		LIMIT $2
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, userID, MaxSuggestions)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	suggestions := []Suggestion{}
+
+	for rows.Next() {
+		var s Suggestion
+		if err := rows.Scan(
+			&s.ID,
+			&s.Username,
+			&s.DisplayName,
+			&s.AvatarURL,
+			&s.FollowersCount,
+			&s.MutualFollows,
+			&s.SharedTags,
+		); err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, s)
+	}
+
+	return suggestions, rows.Err()
+}
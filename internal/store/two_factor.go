@@ -0,0 +1,148 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+// SetTOTPSecret stores a pending secret. It is not used to authenticate
+// until EnableTOTP confirms the user could generate a valid code with it.
+func (s *UsersStore) SetTOTPSecret(ctx context.Context, userID int64, secret string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `UPDATE users SET totp_secret = $1, totp_enabled = false WHERE id = $2`
+
+	_, err := s.db.ExecContext(ctx, query, secret, userID)
+	return err
+}
+
+func (s *UsersStore) GetTOTPSecret(ctx context.Context, userID int64) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `SELECT totp_secret FROM users WHERE id = $1`
+
+	var secret sql.NullString
+	if err := s.db.QueryRowContext(ctx, query, userID).Scan(&secret); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return "", ErrNotFound
+		default:
+			return "", err
+		}
+	}
+
+	if !secret.Valid {
+		return "", ErrNotFound
+	}
+
+	return secret.String, nil
+}
+
+// EnableTOTP turns on two-factor authentication and replaces the user's
+// recovery codes with the given hashed ones.
+func (s *UsersStore) EnableTOTP(ctx context.Context, userID int64, recoveryCodes []string) error {
+	return withTransaction(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `UPDATE users SET totp_enabled = true WHERE id = $1 AND totp_secret IS NOT NULL`
+
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			return err
+		}
+
+		if err := s.deleteRecoveryCodes(ctx, tx, userID); err != nil {
+			return err
+		}
+
+		query = `INSERT INTO user_recovery_codes (user_id, code) VALUES ($1, $2)`
+
+		for _, code := range recoveryCodes {
+			if _, err := tx.ExecContext(ctx, query, userID, code); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *UsersStore) DisableTOTP(ctx context.Context, userID int64) error {
+	return withTransaction(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `UPDATE users SET totp_secret = NULL, totp_enabled = false WHERE id = $1`
+
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			return err
+		}
+
+		return s.deleteRecoveryCodes(ctx, tx, userID)
+	})
+}
+
+// UseTOTPStep records step as the last TOTP time step accepted for the
+// user. It returns ErrNotFound when that step, or a later one, was already
+// used, so a code cannot be replayed.
+func (s *UsersStore) UseTOTPStep(ctx context.Context, userID int64, step int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+		UPDATE users SET totp_last_step = $2
+		WHERE id = $1 AND (totp_last_step IS NULL OR totp_last_step < $2)
+	`
+
+	res, err := s.db.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// UseRecoveryCode consumes one of the user's hashed recovery codes.
+func (s *UsersStore) UseRecoveryCode(ctx context.Context, userID int64, code string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+		UPDATE user_recovery_codes SET used_at = NOW()
+		WHERE user_id = $1 AND code = $2 AND used_at IS NULL
+	`
+
+	res, err := s.db.ExecContext(ctx, query, userID, code)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *UsersStore) deleteRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int64) error {
+	query := `DELETE FROM user_recovery_codes WHERE user_id = $1`
+
+	_, err := tx.ExecContext(ctx, query, userID)
+	return err
+}
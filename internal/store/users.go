@@ -7,36 +7,13 @@ import (
 	"encoding/hex"
 	"errors"
 	"time"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
-type password struct {
-	text *string
-	hash []byte
-}
-
 var (
 	ErrDuplicateEmail    = errors.New("an user with that email already exists")
 	ErrDuplicateUsername = errors.New("an user with that username already exists")
 )
 
-func (p *password) Set(text string) error {
-	hash, err := bcrypt.GenerateFromPassword([]byte(text), bcrypt.DefaultCost)
-	if err != nil {
-		return err
-	}
-
-	p.text = &text
-	p.hash = hash
-
-	return nil
-}
-
-func (p *password) Compare(password string) error {
-	return bcrypt.CompareHashAndPassword(p.hash, []byte(password))
-}
-
 type User struct {
 	ID        int64    `json:"id"`
 	Username  string   `json:"username"`
@@ -45,22 +22,44 @@ type User struct {
 	CreatedAt string   `json:"created_at"`
 	IsActive  bool     `json:"is_active"`
 	RoleId    int64    `json:"role_id"`
+	// Language is the preferred language for emails, such as en or es
+	Language string `json:"language"`
+
+	DisplayName string `json:"display_name"`
+	Bio         string `json:"bio"`
+	AvatarURL   string `json:"avatar_url"`
+	Location    string `json:"location"`
+	// IsPrivate accounts approve their followers, and only they see their posts
+	IsPrivate bool `json:"is_private"`
+
+	// the follow counts only include active users, like the follow lists
+	FollowersCount int64 `json:"followers_count"`
+	FollowingCount int64 `json:"following_count"`
+	PostsCount     int64 `json:"posts_count"`
+
+	TwoFactorEnabled bool `json:"two_factor_enabled"`
+	// DeletionScheduledAt is when the account will be deleted, unless the
+	// user cancels it before
+	DeletionScheduledAt *string `json:"deletion_scheduled_at,omitempty"`
 
 	Role Role `json:"role"`
 }
 
 type UsersStore struct {
 	db *sql.DB
+	// hashing is how new password hashes are made
+	hashing PasswordHashing
 }
 
 func (s *UsersStore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 	query := `
-		INSERT INTO users (username, password, email, role_id) 
+		INSERT INTO users (username, password, email, role_id, language) 
 		VALUES ($1, $2, $3, 
 			(SELECT r.id FROM roles r
-			WHERE r.name = $4)
+			WHERE r.name = $4),
+			$5
 		) 
 		RETURNING id, created_at
 	`
@@ -70,7 +69,11 @@ func (s *UsersStore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
 		roleName = "user"
 	}
 
-	err := tx.QueryRowContext(ctx, query, user.Username, user.Password.hash, user.Email, user.Role.Name).Scan(
+	if user.Language == "" {
+		user.Language = "en"
+	}
+
+	err := tx.QueryRowContext(ctx, query, user.Username, user.Password.hash, user.Email, user.Role.Name, user.Language).Scan(
 		&user.ID,
 		&user.CreatedAt,
 	)
@@ -92,8 +95,15 @@ func (s *UsersStore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
 func (s *UsersStore) GetByID(ctx context.Context, userID int64) (*User, error) {
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
+
+	return getUserByID(ctx, s.db, userID)
+}
+
+func getUserByID(ctx context.Context, db queryRower, userID int64) (*User, error) {
 	query := `
-		SELECT u.id, u.username, u.email, u.created_at, u.role_id,
+		SELECT u.id, u.username, u.email, u.created_at, u.role_id, u.language, u.totp_enabled,
+		u.display_name, u.bio, u.avatar_url, u.location, u.is_private,
+		u.followers_count, u.following_count, u.posts_count, u.deletion_scheduled_at,
 		r.*
 		FROM users u
 		INNER JOIN roles r ON r.id = u.role_id
@@ -102,12 +112,23 @@ func (s *UsersStore) GetByID(ctx context.Context, userID int64) (*User, error) {
 
 	user := User{}
 
-	err := s.db.QueryRowContext(ctx, query, userID).Scan(
+	err := db.QueryRowContext(ctx, query, userID).Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
 		&user.CreatedAt,
 		&user.RoleId,
+		&user.Language,
+		&user.TwoFactorEnabled,
+		&user.DisplayName,
+		&user.Bio,
+		&user.AvatarURL,
+		&user.Location,
+		&user.IsPrivate,
+		&user.FollowersCount,
+		&user.FollowingCount,
+		&user.PostsCount,
+		&user.DeletionScheduledAt,
 		&user.Role.ID,
 		&user.Role.Name,
 		&user.Role.Level,
@@ -126,7 +147,9 @@ func (s *UsersStore) GetByID(ctx context.Context, userID int64) (*User, error) {
 	return &user, nil
 }
 
-func (s *UsersStore) CreateAndInvite(ctx context.Context, user *User, token string, invitationExp time.Duration) error {
+// CreateAndInvite creates the user and its invitation, and queues the
+// invitation email in the same transaction.
+func (s *UsersStore) CreateAndInvite(ctx context.Context, user *User, token string, invitationExp time.Duration, email *OutboxEmail) error {
 	return withTransaction(s.db, ctx, func(tx *sql.Tx) error {
 		if err := s.Create(ctx, tx, user); err != nil {
 			return err
@@ -136,6 +159,10 @@ func (s *UsersStore) CreateAndInvite(ctx context.Context, user *User, token stri
 			return err
 		}
 
+		if err := enqueueEmail(ctx, tx, email); err != nil {
+			return err
+		}
+
 		return nil
 	})
 }
@@ -241,43 +268,178 @@ func (s *UsersStore) deleteUserInvitations(ctx context.Context, tx *sql.Tx, user
 	return nil
 }
 
-func (s *UsersStore) delete(ctx context.Context, tx *sql.Tx, userID int64) error {
-	query := `DELETE FROM users WHERE id = $1`
+// Delete removes the user right away, along with all of their content.
+func (s *UsersStore) Delete(ctx context.Context, userID int64) error {
+	return withTransaction(s.db, ctx, func(tx *sql.Tx) error {
+		return s.purge(ctx, tx, userID)
+	})
+}
 
+func (s *UsersStore) GetByEmail(ctx context.Context, email string) (*User, error) {
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	_, err := tx.ExecContext(ctx, query, userID)
-	if err != nil {
-		return err
+	query := `
+		SELECT
+			u.id, u.username, u.email, u.created_at, u.password, u.language, u.totp_enabled
+		FROM users u
+		WHERE u.email = $1 and u.is_active
+	`
+
+	user := &User{}
+
+	if err := s.db.QueryRowContext(ctx, query, email).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.CreatedAt,
+		&user.Password.hash,
+		&user.Language,
+		&user.TwoFactorEnabled,
+	); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
 	}
 
-	return nil
+	return user, nil
+}
+
+// CreatePasswordReset stores a reset token for the user and queues the
+// email with the link in the same transaction.
+func (s *UsersStore) CreatePasswordReset(ctx context.Context, userID int64, token string, exp time.Duration, email *OutboxEmail) error {
+	return withTransaction(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
 
+		query := `
+			INSERT INTO password_resets (token, user_id, expiry)
+			VALUES ($1, $2, $3)
+		`
+
+		if _, err := tx.ExecContext(ctx, query, token, userID, time.Now().Add(exp)); err != nil {
+			return err
+		}
+
+		return enqueueEmail(ctx, tx, email)
+	})
 }
 
-func (s *UsersStore) Delete(ctx context.Context, userID int64) error {
+// ResetPassword sets user's password to the one identified by the reset
+// token and invalidates every outstanding reset token of that user.
+func (s *UsersStore) ResetPassword(ctx context.Context, token string, user *User) error {
 	return withTransaction(s.db, ctx, func(tx *sql.Tx) error {
-		if err := s.delete(ctx, tx, userID); err != nil {
+		u, err := s.getUserFromPasswordReset(ctx, tx, token)
+		if err != nil {
 			return err
 		}
 
-		if err := s.deleteUserInvitations(ctx, tx, userID); err != nil {
+		user.ID = u.ID
+		user.Username = u.Username
+		user.Email = u.Email
+
+		if err := s.updatePassword(ctx, tx, user.ID, user.Password.hash); err != nil {
 			return err
 		}
+
+		if err := s.deletePasswordResets(ctx, tx, user.ID); err != nil {
+			return err
+		}
+
 		return nil
 	})
 }
 
-func (s *UsersStore) GetByEmail(ctx context.Context, email string) (*User, error) {
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
-	defer cancel()
+// ChangePassword saves the new password of the user, invalidates the
+// outstanding reset tokens and queues the notification email in the same
+// transaction.
+func (s *UsersStore) ChangePassword(ctx context.Context, user *User, email *OutboxEmail) error {
+	return withTransaction(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.updatePassword(ctx, tx, user.ID, user.Password.hash); err != nil {
+			return err
+		}
+
+		if err := s.deletePasswordResets(ctx, tx, user.ID); err != nil {
+			return err
+		}
+
+		return enqueueEmail(ctx, tx, email)
+	})
+}
 
+func (s *UsersStore) getUserFromPasswordReset(ctx context.Context, tx *sql.Tx, token string) (*User, error) {
 	query := `
 		SELECT
-			u.id, u.username, u.email, u.created_at, u.password
+			u.id, u.username, u.email, u.created_at
 		FROM users u
-		WHERE u.email = $1 and u.is_active
+		INNER JOIN password_resets pr ON u.id = pr.user_id
+		WHERE pr.token = $1 AND pr.expiry > $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	hash := sha256.Sum256([]byte(token))
+	hashToken := hex.EncodeToString(hash[:])
+
+	user := &User{}
+
+	if err := tx.QueryRowContext(ctx, query, hashToken, time.Now()).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.CreatedAt,
+	); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+func (s *UsersStore) updatePassword(ctx context.Context, tx *sql.Tx, userID int64, hash []byte) error {
+	query := `UPDATE users SET password = $1 WHERE id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	if _, err := tx.ExecContext(ctx, query, hash, userID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *UsersStore) deletePasswordResets(ctx context.Context, tx *sql.Tx, userID int64) error {
+	query := `DELETE FROM password_resets WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetPendingActivation returns the inactive user with the given email, the
+// one an activation email can be resent to.
+func (s *UsersStore) GetPendingActivation(ctx context.Context, email string) (*User, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+		SELECT id, username, email, created_at, language
+		FROM users
+		WHERE email = $1 AND NOT is_active
 	`
 
 	user := &User{}
@@ -287,7 +449,7 @@ func (s *UsersStore) GetByEmail(ctx context.Context, email string) (*User, error
 		&user.Username,
 		&user.Email,
 		&user.CreatedAt,
-		&user.Password.hash,
+		&user.Language,
 	); err != nil {
 		switch err {
 		case sql.ErrNoRows:
@@ -299,3 +461,102 @@ func (s *UsersStore) GetByEmail(ctx context.Context, email string) (*User, error
 
 	return user, nil
 }
+
+// RotateInvitation replaces the invitations of the inactive user by a new
+// one and queues the email with its link in the same transaction. It returns
+// ErrNotFound when the user was activated meanwhile.
+func (s *UsersStore) RotateInvitation(ctx context.Context, userID int64, token string, invitationExp time.Duration, email *OutboxEmail) error {
+	return withTransaction(s.db, ctx, func(tx *sql.Tx) error {
+		query := `SELECT id FROM users WHERE id = $1 AND NOT is_active FOR UPDATE`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var id int64
+		if err := tx.QueryRowContext(ctx, query, userID).Scan(&id); err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if err := s.deleteUserInvitations(ctx, tx, userID); err != nil {
+			return err
+		}
+
+		if err := s.createUserInvitation(ctx, tx, token, invitationExp, userID); err != nil {
+			return err
+		}
+
+		return enqueueEmail(ctx, tx, email)
+	})
+}
+
+func (s *UsersStore) DeleteExpiredInvitations(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `DELETE FROM user_invitations WHERE expiry < NOW()`
+
+	res, err := s.db.ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// DeleteUnactivated removes the accounts that were never activated and were
+// created more than gracePeriod ago, purging them like any other deleted
+// account so their queued activation email and login attempts go too.
+func (s *UsersStore) DeleteUnactivated(ctx context.Context, gracePeriod time.Duration) (int64, error) {
+	var deleted int64
+
+	err := withTransaction(s.db, ctx, func(tx *sql.Tx) error {
+		ids, err := s.lockUnactivated(ctx, tx, time.Now().Add(-gracePeriod))
+		if err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			if err := s.purge(ctx, tx, id); err != nil {
+				return err
+			}
+		}
+
+		deleted = int64(len(ids))
+		return nil
+	})
+
+	return deleted, err
+}
+
+// lockUnactivated returns the inactive accounts created before the given
+// time, locking them so an activation waits for their deletion.
+func (s *UsersStore) lockUnactivated(ctx context.Context, tx *sql.Tx, createdBefore time.Time) ([]int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `SELECT id FROM users WHERE NOT is_active AND created_at < $1 FOR UPDATE`
+
+	rows, err := tx.QueryContext(ctx, query, createdBefore)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	ids := []int64{}
+
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
@@ -0,0 +1,76 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestRotateInvitation(t *testing.T) {
+	ctx := context.Background()
+	email := &OutboxEmail{Template: "user_invitation.templ", Username: "ana", Email: "ana@example.com"}
+
+	t.Run("should queue the email with the new invitation", func(t *testing.T) {
+		storage, mock := newTestDB(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT id FROM users WHERE id = \$1 AND NOT is_active FOR UPDATE`).
+			WithArgs(int64(7)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+		mock.ExpectExec(`DELETE FROM user_invitations WHERE user_id = \$1`).
+			WithArgs(int64(7)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`INSERT INTO user_invitations`).
+			WithArgs("hash", int64(7), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(`INSERT INTO email_outbox`).
+			WithArgs("user_invitation.templ", "ana", "ana@example.com", sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, "2026-01-01"))
+		mock.ExpectCommit()
+
+		if err := storage.Users.RotateInvitation(ctx, 7, "hash", time.Hour, email); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("should keep the invitation of a user activated meanwhile", func(t *testing.T) {
+		storage, mock := newTestDB(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT id FROM users`).
+			WithArgs(int64(7)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectRollback()
+
+		if err := storage.Users.RotateInvitation(ctx, 7, "hash", time.Hour, email); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestDeleteUnactivated(t *testing.T) {
+	storage, mock := newTestDB(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id FROM users WHERE NOT is_active AND created_at < \$1 FOR UPDATE`).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	for _, table := range []string{"comments", "posts", "followers", "follow_requests", "user_invitations", "email_outbox", "login_attempts", "users"} {
+		mock.ExpectExec(`DELETE FROM ` + table + `\s+WHERE`).
+			WithArgs(int64(7)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+
+	deleted, err := storage.Users.DeleteUnactivated(context.Background(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if deleted != 1 {
+		t.Errorf("expected 1 account to be deleted, got %d", deleted)
+	}
+}